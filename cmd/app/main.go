@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 	"time"
-	"ust_chess/internal/board"
+	"ust_chess/internal/server"
 	"ust_chess/internal/types"
 
 	"github.com/labstack/echo/v4"
@@ -18,10 +21,6 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	game = board.NewGame([]types.Piece{})
-)
-
 type Template struct {
 	templates *template.Template
 }
@@ -42,15 +41,15 @@ func NewTemplate() *Template {
 			"name": func(x types.Figure) string {
 				return x.Name()
 			},
+			"seat": func(color, name string, room any) map[string]any {
+				return map[string]any{"Color": color, "Name": name, "Room": room}
+			},
 		}).ParseGlob("./web/*")),
 	}
 }
 
 // TODO:
-// Вынести логику серера в отдельный пакет
-// Мультипреер по комнатам. Луше сразу по комнатам. Не уверен что получится промежуточно сделать просто два игрока.
 // Может быть по приколу отказаться от Echo и сделать самописный сервер на базовом http/net. Вроде неплохое обучение.
-// Потом может быть систему аккаунтов примитивную. Авторизацию через битрикс лол.
 func main() {
 
 	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.DateTime}).With().Timestamp().Logger()
@@ -58,88 +57,61 @@ func main() {
 	e := echo.New()
 	e.Use(middleware.Logger())
 	e.Renderer = NewTemplate()
-	e.GET("/", Hello)
-	e.GET("/move", Move)
-	e.GET("/restart", Restart)
-	e.Logger.Fatal(e.Start(":1337"))
-}
-
-func Hello(c echo.Context) error {
-	return c.Render(http.StatusOK, "board.html", game.GetForRender())
-}
-
-var (
-	ErrMissingParameter = errors.New("required parameter missing")
-	ErrWrongParameter   = errors.New("wrong value")
-)
-
-func Move(c echo.Context) error {
-	ix_str := c.QueryParam("ix")
-	if ix_str == "" {
-		c.Error(errors.Join(ErrMissingParameter, errors.New("ix")))
-	}
-	ix, err := strconv.Atoi(ix_str)
-	if err != nil {
-		c.Error(errors.Join(ErrWrongParameter, errors.New("ix"), err))
+	srv := server.New(sessionSecret(), storage())
+	if err := srv.Load(); err != nil {
+		log.Fatal().Err(err).Msg("can't load storage")
 	}
-	iy_str := c.QueryParam("iy")
-	if iy_str == "" {
-		c.Error(errors.Join(ErrMissingParameter, errors.New("iy")))
+	srv.Routes(e)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go srv.Run(ctx)
+	go func() {
+		if err := e.Start(":1337"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("server failed")
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // second signal kills at once
+	log.Info().Msg("shutting down")
+	srv.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("requests not finished")
 	}
-	iy, err := strconv.Atoi(c.QueryParam("iy"))
-	if err != nil {
-		c.Error(errors.Join(ErrWrongParameter, errors.New("iy"), err))
-	}
-	fx_str := c.QueryParam("fx")
-	if fx_str == "" {
-		c.Error(errors.Join(ErrMissingParameter, errors.New("fx")))
-	}
-	fx, err := strconv.Atoi(c.QueryParam("fx"))
-	if err != nil {
-		c.Error(errors.Join(ErrWrongParameter, errors.New("fx"), err))
+	if err := srv.Close(); err != nil {
+		log.Error().Err(err).Msg("storage not closed")
 	}
-	fy_str := c.QueryParam("fy")
-	if fy_str == "" {
-		c.Error(errors.Join(ErrMissingParameter, errors.New("fy")))
-	}
-	fy, err := strconv.Atoi(c.QueryParam("fy"))
-	if err != nil {
-		c.Error(errors.Join(ErrWrongParameter, errors.New("fy"), err))
-	}
-	move, err := types.GetMove(ix, iy, fx, fy)
-	if err != nil {
-		c.Error(err)
-	}
-
-	err = game.MakeMove(move)
+	log.Info().Msg("stopped")
+}
 
+// storage keeps data in the CHESS_DATA directory. Without it everything
+// lives in memory and is lost on exit.
+func storage() server.Storage {
+	dir := os.Getenv("CHESS_DATA")
+	if dir == "" {
+		log.Warn().Msg("CHESS_DATA is not set, games won't survive restart")
+		return server.NewMemoryStorage()
+	}
+	storage, err := server.NewFileStorage(dir)
 	if err != nil {
-		game.Error = err.Error()
-	} else {
-		game.Error = ""
+		log.Fatal().Err(err).Str("dir", dir).Msg("can't open storage")
 	}
-
-	return c.Render(http.StatusOK, "board.html", game.GetForRender())
+	return storage
 }
 
-// var test_case = []types.Piece{
-// 	types.GP(types.QUEEN, false, types.NewPos(3, 1)),
-// 	types.GP(types.QUEEN, false, types.NewPos(1, 2)),
-// 	types.GP(types.QUEEN, false, types.NewPos(5, 2)),
-// 	types.GP(types.QUEEN, false, types.NewPos(5, 4)),
-// 	types.GP(types.QUEEN, false, types.NewPos(5, 6)),
-// 	types.GP(types.QUEEN, false, types.NewPos(3, 6)),
-// 	types.GP(types.QUEEN, false, types.NewPos(0, 4)),
-// 	types.GP(types.QUEEN, false, types.NewPos(1, 6)),
-// 	//types.GP(types.PAWN, false, types.NewPos(4, 2)),
-// 	//types.GP(types.PAWN, false, types.NewPos(5, 3)),
-
-// 	types.GP(types.KING, true, types.NewPos(3, 4)),
-// }
-
-func Restart(c echo.Context) error {
-	game = board.NewGame([]types.Piece{})
-	c.Logger().Warn("game restated")
-
-	return c.Render(http.StatusOK, "board.html", game.GetForRender())
+// sessionSecret reads the cookie signing key from CHESS_SECRET. Without it
+// a random key is used and every session dies with the process.
+func sessionSecret() []byte {
+	if secret := os.Getenv("CHESS_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Warn().Msg("CHESS_SECRET is not set, sessions won't survive restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
 }
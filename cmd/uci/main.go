@@ -0,0 +1,13 @@
+// Command uci plays with the rules and the engine of the project through
+// the Universal Chess Interface on stdin and stdout, so chess GUIs and
+// testing tools can use it. See https://www.shredderchess.com/download/div/uci.zip
+// for the protocol.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	newSession(os.Stdout).run(os.Stdin)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"ust_chess/internal/engine"
+	"ust_chess/internal/types"
+)
+
+const (
+	// movesToGo is how many moves the clock is shared between when the
+	// GUI doesn't tell.
+	movesToGo = 30
+	// timeMargin is kept on the clock for the GUI to get the move.
+	timeMargin = 50 * time.Millisecond
+	minTime    = 10 * time.Millisecond
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrBadArgument    = errors.New("bad argument")
+)
+
+// session is one conversation with a GUI. Searches run in the background,
+// so the GUI can stop them.
+type session struct {
+	mu       sync.Mutex // guards out
+	out      io.Writer
+	pos      types.Board
+	cancel   context.CancelFunc // of the running search
+	done     chan struct{}      // closed once the running search printed its move
+	infinite bool               // the running search waits for "stop"
+}
+
+func newSession(out io.Writer) *session {
+	s := &session{out: out}
+	s.pos, _ = types.ParseFEN(types.StartFEN)
+	return s
+}
+
+// run handles commands until "quit" or the end of the input. At the end of
+// the input a running search is let to finish.
+func (s *session) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			s.stop()
+			return
+		}
+		if err := s.handle(fields[0], fields[1:]); err != nil {
+			// joined errors come on several lines
+			s.printf("info string %s", strings.ReplaceAll(err.Error(), "\n", ": "))
+		}
+	}
+	if s.infinite {
+		s.stop()
+	}
+	s.wait()
+}
+
+func (s *session) handle(command string, args []string) error {
+	switch command {
+	case "uci":
+		s.printf("id name pwr_chess")
+		s.printf("id author pwr_chess authors")
+		s.printf("uciok")
+	case "isready":
+		s.printf("readyok")
+	case "ucinewgame":
+		s.stop()
+		s.pos, _ = types.ParseFEN(types.StartFEN)
+	case "position":
+		s.stop()
+		return s.position(args)
+	case "go":
+		s.stop()
+		return s.search(args)
+	case "stop":
+		s.stop()
+	case "debug", "setoption", "register", "ponderhit":
+		// nothing to set
+	default:
+		return errors.Join(ErrUnknownCommand, errors.New(command))
+	}
+	return nil
+}
+
+// position sets up "startpos" or "fen <fen>", then plays the "moves"
+// after it. The position doesn't change if anything is wrong.
+func (s *session) position(args []string) error {
+	var pos types.Board
+	var err error
+	switch {
+	case len(args) > 0 && args[0] == "startpos":
+		pos, _ = types.ParseFEN(types.StartFEN)
+		args = args[1:]
+	case len(args) > 0 && args[0] == "fen":
+		end := 1
+		for end < len(args) && args[end] != "moves" {
+			end++
+		}
+		if pos, err = types.ParseFEN(strings.Join(args[1:end], " ")); err != nil {
+			return err
+		}
+		args = args[end:]
+	default:
+		return errors.Join(ErrBadArgument, errors.New("startpos or fen expected"))
+	}
+	if len(args) > 0 {
+		if args[0] != "moves" {
+			return errors.Join(ErrBadArgument, errors.New(args[0]))
+		}
+		for _, notation := range args[1:] {
+			move, err := types.ParseMove(notation)
+			if err != nil {
+				return err
+			}
+			if move, err = pos.Legal(move); err != nil {
+				return errors.Join(err, errors.New(notation))
+			}
+			pos.Make(move)
+		}
+	}
+	s.pos = pos
+	return nil
+}
+
+// search starts looking for a move with the limits of the "go" command.
+func (s *session) search(args []string) error {
+	limits := map[string]int{}
+	infinite := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "infinite":
+			infinite = true
+		case "ponder":
+		case "searchmoves":
+			return errors.Join(ErrBadArgument, errors.New("searchmoves is not supported"))
+		default:
+			if i+1 == len(args) {
+				return errors.Join(ErrBadArgument, errors.New(args[i]))
+			}
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return errors.Join(ErrBadArgument, errors.New(args[i]), err)
+			}
+			limits[args[i]] = value
+			i++
+		}
+	}
+
+	options := engine.Options{Depth: limits["depth"], Nodes: limits["nodes"]}
+	if !infinite {
+		options.Time = budget(limits, s.pos.IsBlackTurn())
+	}
+	start := time.Now()
+	options.Report = func(result engine.Result) {
+		elapsed := time.Since(start)
+		s.printf("info depth %d score %s nodes %d nps %d time %d pv %s",
+			result.Depth, score(result.Score), result.Nodes,
+			int(float64(result.Nodes)/max(elapsed.Seconds(), 0.001)), elapsed.Milliseconds(),
+			result.Move.Notation())
+	}
+	search := engine.New(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done, s.infinite = cancel, make(chan struct{}), infinite
+	pos, done := s.pos.Clone(), s.done
+	go func() {
+		defer close(done)
+		result, err := search.Search(ctx, &pos)
+		if err != nil {
+			s.printf("bestmove 0000")
+			return
+		}
+		s.printf("bestmove %s", result.Move.Notation())
+	}()
+	return nil
+}
+
+// budget is the time for a move: "movetime" if it is set, else a share of
+// the clock of the side to move. Zero without limits.
+func budget(limits map[string]int, isBlack bool) time.Duration {
+	if movetime, ok := limits["movetime"]; ok {
+		return max(time.Duration(movetime)*time.Millisecond, minTime)
+	}
+	left, inc := limits["wtime"], limits["winc"]
+	if isBlack {
+		left, inc = limits["btime"], limits["binc"]
+	}
+	if left == 0 {
+		return 0
+	}
+	togo := movesToGo
+	if limits["movestogo"] > 0 {
+		togo = limits["movestogo"]
+	}
+	clock := time.Duration(left) * time.Millisecond
+	share := clock/time.Duration(togo) + time.Duration(inc)*time.Millisecond/2
+	return max(min(share, clock-timeMargin), minTime)
+}
+
+// score in UCI form: centipawns or moves to mate.
+func score(cp int) string {
+	switch {
+	case cp > engine.Mate-100:
+		return fmt.Sprintf("mate %d", (engine.Mate-cp+1)/2)
+	case cp < -engine.Mate+100:
+		return fmt.Sprintf("mate %d", -(engine.Mate+cp)/2)
+	}
+	return fmt.Sprintf("cp %d", cp)
+}
+
+// stop ends the running search and waits for its move.
+func (s *session) stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wait()
+}
+
+func (s *session) wait() {
+	if s.done != nil {
+		<-s.done
+	}
+	s.cancel, s.done, s.infinite = nil, nil, false
+}
+
+func (s *session) printf(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.out, format+"\n", args...)
+}
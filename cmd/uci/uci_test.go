@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"ust_chess/internal/types"
+)
+
+// play feeds the script to a session and returns what it printed.
+func play(t *testing.T, script ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	newSession(&out).run(strings.NewReader(strings.Join(script, "\n") + "\n"))
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func bestMoves(lines []string) []string {
+	var moves []string
+	for _, line := range lines {
+		if move, ok := strings.CutPrefix(line, "bestmove "); ok {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+func TestHandshake(t *testing.T) {
+	lines := play(t, "uci", "isready", "quit", "isready")
+	if !slices.Contains(lines, "uciok") || lines[len(lines)-1] != "readyok" || lines[len(lines)-2] != "uciok" {
+		t.Errorf("got %q", lines)
+	}
+	if slices.Index(lines, "readyok") != len(lines)-1 {
+		t.Errorf("answered after quit: %q", lines)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		script []string
+		want   string // best move, "" for any legal one
+		turn   string // fen of the position searched
+	}{
+		{
+			name:   "mate in one",
+			script: []string{"position fen r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "go depth 3"},
+			want:   "h5f7",
+		},
+		{
+			name:   "moves from the start",
+			script: []string{"position startpos moves f2f3 e7e5 g2g4", "go depth 2"},
+			want:   "d8h4",
+		},
+		{
+			name:   "moves after fen",
+			script: []string{"position fen 7k/P7/8/8/8/8/8/K7 w - - 0 1 moves a1b1 h8g8", "go depth 2"},
+			want:   "a7a8q",
+		},
+		{
+			name:   "mated",
+			script: []string{"position startpos moves f2f3 e7e5 g2g4 d8h4", "go depth 2"},
+			want:   "0000",
+		},
+		{
+			name:   "bad move keeps the position",
+			script: []string{"position startpos moves f2f3 e7e5 g2g4", "position startpos moves e2e5", "go depth 2"},
+			want:   "d8h4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := play(t, tt.script...)
+			if moves := bestMoves(lines); len(moves) != 1 || moves[0] != tt.want {
+				t.Errorf("got %q", lines)
+			}
+		})
+	}
+}
+
+// TestCastlingWithoutRook: a FEN may give rights the pieces don't have, the
+// castling is refused instead of moving a rook that isn't there.
+func TestCastlingWithoutRook(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/8/4K3 w K - 0 1"
+	lines := play(t, "position fen "+fen, "position fen "+fen+" moves e1g1", "go depth 1")
+	if !slices.ContainsFunc(lines, func(line string) bool { return strings.HasPrefix(line, "info string") }) {
+		t.Errorf("castling accepted: %q", lines)
+	}
+	moves := bestMoves(lines)
+	if len(moves) != 1 {
+		t.Fatalf("got %q", lines)
+	}
+	move, err := types.ParseMove(moves[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos, _ := types.ParseFEN(fen)
+	if _, err := pos.Legal(move); err != nil {
+		t.Errorf("%s: %v", moves[0], err)
+	}
+}
+
+func TestGo(t *testing.T) {
+	legal := func(t *testing.T, lines []string) {
+		t.Helper()
+		moves := bestMoves(lines)
+		if len(moves) != 1 {
+			t.Fatalf("got %q", lines)
+		}
+		move, err := types.ParseMove(moves[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos, _ := types.ParseFEN(types.StartFEN)
+		if _, err := pos.Legal(move); err != nil {
+			t.Errorf("%s: %v", moves[0], err)
+		}
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		start := time.Now()
+		legal(t, play(t, "position startpos", "go infinite", "stop", "quit"))
+		if time.Since(start) > time.Second {
+			t.Errorf("stopped after %v", time.Since(start))
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		start := time.Now()
+		legal(t, play(t, "position startpos", "go wtime 3000 btime 3000 winc 0 binc 0"))
+		if spent := time.Since(start); spent > 500*time.Millisecond {
+			t.Errorf("spent %v of 3s", spent)
+		}
+	})
+
+	t.Run("movetime", func(t *testing.T) {
+		start := time.Now()
+		lines := play(t, "position startpos", "go movetime 200")
+		legal(t, lines)
+		if spent := time.Since(start); spent > 400*time.Millisecond {
+			t.Errorf("spent %v", spent)
+		}
+		if !strings.HasPrefix(lines[0], "info depth 1 score cp ") {
+			t.Errorf("no info: %q", lines)
+		}
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		legal(t, play(t, "position startpos", "go nodes 300"))
+	})
+
+	t.Run("mate score", func(t *testing.T) {
+		lines := play(t, "position startpos moves f2f3 e7e5 g2g4", "go depth 2")
+		if !slices.ContainsFunc(lines, func(line string) bool { return strings.Contains(line, "score mate 1 ") }) {
+			t.Errorf("got %q", lines)
+		}
+	})
+
+	t.Run("quit stops", func(t *testing.T) {
+		start := time.Now()
+		legal(t, play(t, "position startpos", "go infinite", "quit"))
+		if time.Since(start) > time.Second {
+			t.Errorf("quit after %v", time.Since(start))
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	for _, command := range []string{
+		"fly",
+		"position",
+		"position fen 8/8/8/8/8/8/8/8 w - -",
+		"position startpos moves e2e5",
+		"position startpos e2e4",
+		"go depth",
+		"go depth deep",
+	} {
+		lines := play(t, command, "isready")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "info string ") || lines[1] != "readyok" {
+			t.Errorf("%s: got %q", command, lines)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package analysis finds tactical motifs on a board for training: pins,
+// skewers, forks, discovered attacks, double checks and overloaded
+// defenders. Motifs are found for both sides and without a search, from
+// the attacks the board keeps for every cell.
+package analysis
+
+import (
+	"ust_chess/internal/types"
+)
+
+type Kind uint8
+
+const (
+	// Pieces: the pinning piece, the pinned one, the one behind it.
+	PIN Kind = iota
+	// Pieces: the attacker, the piece in front, the one behind it.
+	SKEWER
+	// Pieces: the forking piece, then the ones it attacks.
+	FORK
+	// Pieces: the piece to move away, the one behind it, the target.
+	DISCOVERED_ATTACK
+	// Pieces: the checking pieces, then the king.
+	DOUBLE_CHECK
+	// Pieces: the defender, then the attacked pieces it alone defends.
+	OVERLOADED
+)
+
+func (k Kind) String() string {
+	switch k {
+	case PIN:
+		return "pin"
+	case SKEWER:
+		return "skewer"
+	case FORK:
+		return "fork"
+	case DISCOVERED_ATTACK:
+		return "discovered attack"
+	case DOUBLE_CHECK:
+		return "double check"
+	case OVERLOADED:
+		return "overloaded defender"
+	default:
+		return "???"
+	}
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Motif on the board.
+type Motif struct {
+	Kind     Kind
+	White    bool             // side the motif plays for
+	Absolute bool             // a pin to the king, a skewer or discovered attack of the king
+	Pieces   []types.Position // cells of the pieces in the order of the kind
+	Squares  []types.Position // empty cells the attack goes through
+}
+
+// kingWorth makes a king worth more than anything it can be traded for.
+const kingWorth = 10000
+
+func worth(piece *types.Piece) int {
+	if piece.GetType() == types.KING {
+		return kingWorth
+	}
+	return piece.GetType().Value()
+}
+
+var lines = map[types.Figure][][2]int{
+	types.ROOK:   {{1, 0}, {-1, 0}, {0, 1}, {0, -1}},
+	types.BISHOP: {{1, 1}, {1, -1}, {-1, 1}, {-1, -1}},
+	types.QUEEN:  {{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}},
+}
+
+// Motifs finds the motifs of both sides.
+func Motifs(board *types.Board) []Motif {
+	var motifs []Motif
+	for x := range 8 {
+		for y := range 8 {
+			piece := board.GetCell(types.MustNewPos(x, y)).GetPiece()
+			if piece == nil {
+				continue
+			}
+			motifs = append(motifs, alongLines(board, piece)...)
+			if fork, ok := forkOf(board, piece); ok {
+				motifs = append(motifs, fork)
+			}
+			if check, ok := doubleCheck(board, piece); ok {
+				motifs = append(motifs, check)
+			}
+			if overload, ok := overloaded(board, piece); ok {
+				motifs = append(motifs, overload)
+			}
+		}
+	}
+	return motifs
+}
+
+// alongLines finds pins, skewers and discovered attacks of a sliding
+// piece: they are about the first two pieces it meets on a line.
+func alongLines(board *types.Board, slider *types.Piece) []Motif {
+	var motifs []Motif
+	for _, line := range lines[slider.GetType()] {
+		front, behind, squares := look(board, slider.GetPosition(), line)
+		if front == nil || behind == nil || behind.IsWhite() == slider.IsWhite() {
+			continue
+		}
+		motif := Motif{
+			White:    slider.IsWhite(),
+			Absolute: behind.GetType() == types.KING,
+			Pieces:   []types.Position{slider.GetPosition(), front.GetPosition(), behind.GetPosition()},
+			Squares:  squares,
+		}
+		switch {
+		case front.IsWhite() == slider.IsWhite():
+			if !threatened(board, behind, slider) {
+				continue
+			}
+			motif.Kind = DISCOVERED_ATTACK
+			motif.Pieces[0], motif.Pieces[1] = motif.Pieces[1], motif.Pieces[0]
+		case behind.GetType() == types.KING || worth(behind) > worth(front) && threatened(board, behind, slider):
+			motif.Kind = PIN
+		case worth(front) > worth(behind) && threatened(board, front, slider) && threatened(board, behind, slider):
+			motif.Kind = SKEWER
+			motif.Absolute = front.GetType() == types.KING
+		default:
+			continue
+		}
+		motifs = append(motifs, motif)
+	}
+	return motifs
+}
+
+// look goes from the cell along the line up to the second piece on it.
+// Empty cells on the way are returned too.
+func look(board *types.Board, from types.Position, line [2]int) (*types.Piece, *types.Piece, []types.Position) {
+	var found []*types.Piece
+	var squares []types.Position
+	for x, y := from.GetX()+line[0], from.GetY()+line[1]; len(found) < 2; x, y = x+line[0], y+line[1] {
+		pos, err := types.NewPos(x, y)
+		if err != nil {
+			break
+		}
+		if piece := board.GetCell(pos).GetPiece(); piece != nil {
+			found = append(found, piece)
+		} else {
+			squares = append(squares, pos)
+		}
+	}
+	for len(found) < 2 {
+		found = append(found, nil)
+	}
+	return found[0], found[1], squares
+}
+
+// threatened tells if the attacker wins something taking the target: a
+// king, a piece worth more or one nobody defends.
+func threatened(board *types.Board, target, attacker *types.Piece) bool {
+	if attacker.GetType() == types.KING {
+		return defenders(board, target) == 0 && target.GetType() != types.KING
+	}
+	return target.GetType() == types.KING || worth(target) > worth(attacker) || defenders(board, target) == 0
+}
+
+func defenders(board *types.Board, piece *types.Piece) int {
+	n := 0
+	for _, other := range board.GetCell(piece.GetPosition()).GetTargetedBy() {
+		if other.IsWhite() == piece.IsWhite() {
+			n++
+		}
+	}
+	return n
+}
+
+func attackers(board *types.Board, piece *types.Piece) []*types.Piece {
+	var found []*types.Piece
+	for _, other := range board.GetCell(piece.GetPosition()).GetTargetedBy() {
+		if other.IsWhite() != piece.IsWhite() {
+			found = append(found, other)
+		}
+	}
+	return found
+}
+
+// forkOf: the piece threatens two enemy pieces or more at once.
+func forkOf(board *types.Board, piece *types.Piece) (Motif, bool) {
+	fork := Motif{Kind: FORK, White: piece.IsWhite(), Pieces: []types.Position{piece.GetPosition()}}
+	for _, cell := range piece.GetTargetedCells() {
+		target := board.GetCell(cell).GetPiece()
+		if target == nil || target.IsWhite() == piece.IsWhite() || !threatened(board, target, piece) {
+			continue
+		}
+		fork.Pieces = append(fork.Pieces, cell)
+		fork.Absolute = fork.Absolute || target.GetType() == types.KING
+	}
+	return fork, len(fork.Pieces) > 2
+}
+
+func doubleCheck(board *types.Board, king *types.Piece) (Motif, bool) {
+	if king.GetType() != types.KING {
+		return Motif{}, false
+	}
+	checks := attackers(board, king)
+	check := Motif{Kind: DOUBLE_CHECK, White: !king.IsWhite(), Absolute: true}
+	for _, checker := range checks {
+		check.Pieces = append(check.Pieces, checker.GetPosition())
+	}
+	check.Pieces = append(check.Pieces, king.GetPosition())
+	return check, len(checks) > 1
+}
+
+// overloaded: the piece is the only defender of two attacked pieces or
+// more, and can't take back on both cells. One attacker of them all takes
+// only once, so it is no overload.
+func overloaded(board *types.Board, defender *types.Piece) (Motif, bool) {
+	overload := Motif{Kind: OVERLOADED, White: !defender.IsWhite(), Pieces: []types.Position{defender.GetPosition()}}
+	enemies := map[*types.Piece]bool{}
+	for _, cell := range defender.GetTargetedCells() {
+		piece := board.GetCell(cell).GetPiece()
+		if piece == nil || piece.IsWhite() != defender.IsWhite() || defenders(board, piece) != 1 {
+			continue
+		}
+		// The defence matters when taking is a gain only without it.
+		attacking := attackers(board, piece)
+		needed := len(attacking) > 0
+		for _, attacker := range attacking {
+			needed = needed && worth(attacker) >= worth(piece)
+		}
+		if needed {
+			overload.Pieces = append(overload.Pieces, cell)
+			for _, attacker := range attacking {
+				enemies[attacker] = true
+			}
+		}
+	}
+	return overload, len(overload.Pieces) > 2 && len(enemies) > 1
+}
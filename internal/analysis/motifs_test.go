@@ -0,0 +1,100 @@
+package analysis_test
+
+import (
+	"slices"
+	"testing"
+	"ust_chess/internal/analysis"
+	"ust_chess/internal/types"
+)
+
+func motifs(t *testing.T, fen string) []analysis.Motif {
+	t.Helper()
+	b, err := types.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return analysis.Motifs(&b)
+}
+
+func cells(positions []types.Position) []string {
+	var out []string
+	for _, pos := range positions {
+		out = append(out, pos.Notation())
+	}
+	return out
+}
+
+// same motif: the first piece in place, the others in any order.
+func same(motif, want analysis.Motif) bool {
+	got, expected := cells(motif.Pieces), cells(want.Pieces)
+	if motif.Kind != want.Kind || motif.White != want.White || motif.Absolute != want.Absolute ||
+		len(got) != len(expected) || len(got) == 0 || got[0] != expected[0] {
+		return false
+	}
+	slices.Sort(got)
+	slices.Sort(expected)
+	return slices.Equal(got, expected)
+}
+
+func at(t *testing.T, notations ...string) []types.Position {
+	t.Helper()
+	var positions []types.Position
+	for _, notation := range notations {
+		pos, err := types.ParsePos(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+func TestMotifs(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want analysis.Motif
+	}{
+		{"absolute pin", "4k3/8/8/1b6/8/3N4/8/5K2 w - -",
+			analysis.Motif{Kind: analysis.PIN, Absolute: true, Pieces: at(t, "b5", "d3", "f1")}},
+		{"relative pin", "4k3/8/8/1b6/8/3N4/8/5Q1K w - -",
+			analysis.Motif{Kind: analysis.PIN, Pieces: at(t, "b5", "d3", "f1")}},
+		{"skewer", "3q4/8/8/3k4/8/8/8/K2R4 w - -",
+			analysis.Motif{Kind: analysis.SKEWER, White: true, Absolute: true, Pieces: at(t, "d1", "d5", "d8")}},
+		{"fork", "r3k3/2N5/8/8/8/8/8/K7 w - -",
+			analysis.Motif{Kind: analysis.FORK, White: true, Absolute: true, Pieces: at(t, "c7", "a8", "e8")}},
+		{"discovered check", "4k3/8/8/8/8/8/4N3/4R1K1 w - -",
+			analysis.Motif{Kind: analysis.DISCOVERED_ATTACK, White: true, Absolute: true, Pieces: at(t, "e2", "e1", "e8")}},
+		{"double check", "4k3/8/3N4/8/8/8/8/4R1K1 b - -",
+			analysis.Motif{Kind: analysis.DOUBLE_CHECK, White: true, Absolute: true, Pieces: at(t, "e1", "d6", "e8")}},
+		{"overloaded defender", "k7/8/3q4/2n1n3/8/1N6/7B/K7 w - -",
+			analysis.Motif{Kind: analysis.OVERLOADED, White: true, Pieces: at(t, "d6", "c5", "e5")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := motifs(t, tt.fen)
+			if !slices.ContainsFunc(found, func(motif analysis.Motif) bool { return same(motif, tt.want) }) {
+				t.Errorf("no %v in %+v", tt.want.Kind, found)
+			}
+		})
+	}
+}
+
+func TestNoMotifs(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+	}{
+		{"start", types.StartFEN},
+		// Taking the defended bishop behind the knight loses the rook.
+		{"defended behind", "4k3/8/8/8/8/r1NB4/4K3/8 w - -"},
+		{"fork of defended pieces", "4k3/3p4/2p1p3/3P4/8/8/8/4K3 w - -"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found := motifs(t, tt.fen); len(found) > 0 {
+				t.Errorf("found %+v", found)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestAbandonment(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	game := newGame(t, start)
+	play(t, &game, start, "e2e4")
+	if err := game.Apply(board.Event{Type: board.ABORTED, At: start.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if game.Result != board.NO_RESULT || game.Termination != board.NO_FIRST_MOVE {
+		t.Errorf("result %v %v", game.Result, game.Termination)
+	}
+
+	game = newGame(t, start)
+	play(t, &game, start, "e2e4", "e7e5")
+	if err := game.Apply(board.Event{Type: board.ABORTED, At: start.Add(time.Minute)}); !errors.Is(err, board.ErrCantAbort) {
+		t.Errorf("abort after both moved: %v", err)
+	}
+	if err := game.Apply(board.Event{Type: board.WIN_CLAIMED, At: start.Add(time.Minute), IsBlack: true}); err != nil {
+		t.Fatal(err)
+	}
+	if game.Result != board.BLACK_WON || game.Termination != board.ABANDONMENT {
+		t.Errorf("result %v %v", game.Result, game.Termination)
+	}
+
+	game = newGame(t, start)
+	play(t, &game, start, "e2e4", "e7e5")
+	if err := game.Apply(board.Event{Type: board.DRAW_CLAIMED, At: start.Add(time.Minute), Reason: board.ABANDONMENT}); err != nil {
+		t.Fatal(err)
+	}
+	if game.Result != board.DRAW || game.Termination != board.ABANDONMENT {
+		t.Errorf("result %v %v", game.Result, game.Termination)
+	}
+}
@@ -0,0 +1,79 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestPauseAndAdjourn(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tc := board.TimeControl{Base: time.Minute}
+	events := []board.Event{
+		{Type: board.CREATED, At: start, TimeControl: &tc},
+		{Type: board.MOVED, At: start.Add(5 * time.Second), Move: move(t, "e2e4")},
+	}
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	t.Run("pause", func(t *testing.T) {
+		game, err := board.Rebuild(append(events,
+			board.Event{Type: board.PAUSE_OFFERED, At: at(10)},
+			board.Event{Type: board.PAUSE_ACCEPTED, At: at(15), IsBlack: true},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Apply(board.Event{Type: board.MOVED, At: at(20), Move: move(t, "e7e5")}); !errors.Is(err, board.ErrGamePaused) {
+			t.Errorf("move while paused: %v", err)
+		}
+		for _, event := range []board.Event{
+			{Type: board.PAUSE_OFFERED, At: at(3600), IsBlack: true},
+			{Type: board.PAUSE_ACCEPTED, At: at(3610)},
+		} {
+			if err := game.Apply(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Black thought 10s before the pause and 5s after it.
+		if left := game.TimeLeft(at(3615), true); left != 45*time.Second {
+			t.Errorf("black has %v, want 45s", left)
+		}
+	})
+
+	t.Run("adjourn", func(t *testing.T) {
+		game, err := board.Rebuild(events)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Apply(board.Event{Type: board.ADJOURN_OFFERED, At: at(10), IsBlack: true, Move: move(t, "e7e4")}); err == nil {
+			t.Error("illegal move sealed")
+		}
+		if err := game.Apply(board.Event{Type: board.ADJOURN_OFFERED, At: at(10), Move: move(t, "d2d4")}); !errors.Is(err, board.ErrOpponentsTurn) {
+			t.Errorf("move sealed by the side not to move: %v", err)
+		}
+		for _, event := range []board.Event{
+			{Type: board.ADJOURN_OFFERED, At: at(10), IsBlack: true, Move: move(t, "e7e5")},
+			{Type: board.ADJOURN_ACCEPTED, At: at(12)},
+			{Type: board.READY, At: at(86400)},
+		} {
+			if err := game.Apply(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !game.IsAdjourned || len(game.History) != 1 {
+			t.Fatalf("resumed with one player back")
+		}
+		if err := game.Apply(board.Event{Type: board.READY, At: at(86405), IsBlack: true}); err != nil {
+			t.Fatal(err)
+		}
+		if game.IsAdjourned || game.IsPause || len(game.History) != 2 || game.IsBlackTurn {
+			t.Fatalf("sealed move not played: adjourned %v, history %d", game.IsAdjourned, len(game.History))
+		}
+		// Black's clock stopped when the game was adjourned, white's runs
+		// from the resumption.
+		if white, black := game.TimeLeft(at(86410), false), game.TimeLeft(at(86410), true); white != 55*time.Second || black != 53*time.Second {
+			t.Errorf("clocks %v %v, want 55s 53s", white, black)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package board_test
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+// safeMoves are the pseudo-legal moves that don't leave the own king
+// attacked once played.
+func safeMoves(b *types.Board) []types.Move {
+	var moves []types.Move
+	for _, move := range b.PseudoLegalMoves() {
+		white := !b.IsBlackTurn()
+		undo := b.Make(move)
+		if !b.InCheck(white) {
+			moves = append(moves, move)
+		}
+		b.Unmake(undo)
+	}
+	return moves
+}
+
+// TestAttackMaps plays random games and checks that the attacks kept move
+// by move are the ones of a board set up anew, and that the moves found
+// with them are the legal ones: those after which the king is not
+// attacked.
+func TestAttackMaps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fens := []string{
+		types.StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	}
+	random := rand.New(rand.NewPCG(1, 2))
+	for _, fen := range fens {
+		for range 20 {
+			game := fromFEN(t, fen)
+			for ply := 0; ply < 80 && !game.IsEnded(); ply++ {
+				fresh, err := types.ParseFEN(game.Board.FEN())
+				if err != nil {
+					t.Fatal(err)
+				}
+				moves := game.LegalMoves()
+				if want := safeMoves(&fresh); !slices.Equal(moves, want) {
+					t.Fatalf("%s: legal moves %v, want %v", game.Board.FEN(), moves, want)
+				}
+				if game.IsKingChecked != fresh.InCheck(!fresh.IsBlackTurn()) {
+					t.Fatalf("%s: check %v", game.Board.FEN(), game.IsKingChecked)
+				}
+				for x := range 8 {
+					for y := range 8 {
+						pos := types.MustNewPos(x, y)
+						got, want := game.Board.GetCell(pos).GetTargetedBy(), fresh.GetCell(pos).GetTargetedBy()
+						same := len(got) == len(want)
+						for _, piece := range want {
+							same = same && slices.ContainsFunc(got, func(p *types.Piece) bool { return p.GetPosition() == piece.GetPosition() })
+						}
+						if !same {
+							t.Fatalf("%s: attackers of %s %v, want %v", game.Board.FEN(), pos.Notation(), got, want)
+						}
+					}
+				}
+				if len(moves) == 0 {
+					break
+				}
+				if err := game.MakeMoveAt(moves[random.IntN(len(moves))], start); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	game := board.NewGame(nil)
+	out := game.GetForRender()
+	// f3: pawns e2 and g2 and the knight g1
+	if f3 := out.Board[2][2]; f3.WhiteControl != 3 || f3.BlackControl != 0 || f3.Control() != "white" {
+		t.Errorf("f3 %+v", f3)
+	}
+	// e2: king, queen, bishop and knight
+	if e2 := out.Board[1][3]; e2.Defenders != 4 || e2.Attackers != 0 || e2.Hanging() {
+		t.Errorf("e2 %+v", e2)
+	}
+
+	game = fromFEN(t, "4k3/8/8/8/3p4/2N5/4K3/8 w - -")
+	out = game.GetForRender()
+	if c3 := out.Board[2][5]; !c3.Hanging() || c3.Control() != "black" {
+		t.Errorf("c3 %+v", c3)
+	}
+	if e3 := out.Board[2][3]; e3.Control() != "contested" {
+		t.Errorf("e3 %+v", e3)
+	}
+	out.HideControl()
+	if c3 := out.Board[2][5]; c3.Hanging() || c3.Control() != "" {
+		t.Errorf("hidden c3 %+v", c3)
+	}
+}
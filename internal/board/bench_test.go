@@ -0,0 +1,30 @@
+package board_test
+
+import (
+	"testing"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+// BenchmarkGameMoves plays moves on the board of a game, with the attacks
+// of every cell kept up to date, and finds the legal replies.
+func BenchmarkGameMoves(b *testing.B) {
+	moves := []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6", "d2d3", "f8c5", "e1g1", "d7d6"}
+	parsed := make([]types.Move, len(moves))
+	for i, notation := range moves {
+		move, err := types.ParseMove(notation)
+		if err != nil {
+			b.Fatal(err)
+		}
+		parsed[i] = move
+	}
+	b.ResetTimer()
+	for range b.N {
+		game := board.NewGame(nil)
+		for _, move := range parsed {
+			if err := game.MakeMove(move); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package board
+
+import "ust_chess/internal/types"
+
+// Blunder is what the move gives away without any search: the material
+// lost in the exchange on its final cell and the pieces the opponent can
+// win right after it.
+type Blunder struct {
+	Exchange int      // material won (lost if negative) on the final cell
+	Hanging  []string // cells of the pieces left hanging
+}
+
+func (b Blunder) IsBlunder() bool {
+	return b.Exchange < 0 || len(b.Hanging) > 0
+}
+
+// Blunder looks at the move of the side to move. A mate is never one.
+func (g *Game) Blunder(move types.Move) (Blunder, error) {
+	if g.IsEnded() {
+		return Blunder{}, ErrGameEnded
+	}
+	move, err := g.Board.Legal(move)
+	if err != nil {
+		return Blunder{}, err
+	}
+	board := g.Board.Clone()
+	board.Make(move)
+	if board.InCheck(g.IsBlackTurn) && len(board.LegalMoves()) == 0 {
+		return Blunder{}, nil
+	}
+
+	blunder := Blunder{Exchange: g.Board.SEE(move)}
+	for _, piece := range board.HangingPieces(!g.IsBlackTurn) {
+		blunder.Hanging = append(blunder.Hanging, piece.GetPosition().Notation())
+	}
+	return blunder, nil
+}
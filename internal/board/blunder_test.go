@@ -0,0 +1,68 @@
+package board_test
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		want int
+	}{
+		{"free pawn", "4k3/8/8/3p4/4P3/8/8/4K3 w - -", "e4d5", 100},
+		{"pawn for pawn", "4k3/8/2p5/3p4/4P3/8/8/4K3 w - -", "e4d5", 0},
+		{"rook for pawn", "4k3/8/2p5/3p4/8/8/8/3RK3 w - -", "d1d5", -400},
+		{"queen behind the rook", "4k3/8/2p5/3p4/8/8/3R4/3QK3 w - -", "d2d5", -300},
+		{"defender stays away", "4k3/8/8/3p4/8/8/8/3RK3 w - -", "d1d5", 100},
+		{"bishop behind the queen", "4k3/8/4n3/8/8/1Q6/B7/4K3 w - -", "b3e6", 320},
+		{"promotion", "3rk3/2P5/8/8/8/8/8/4K3 w - -", "c7d8q", 400},
+		{"quiet move", "4k3/8/8/3p4/8/8/8/2N1K3 w - -", "c1e2", 0},
+		{"quiet move under a pawn", "4k3/8/8/3p4/8/8/8/2N1K3 w - -", "c1b3", 0},
+		{"quiet move under a pawn lost", "4k3/8/8/8/3p4/8/8/1N2K3 w - -", "b1c3", -320},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := fromFEN(t, tt.fen)
+			if got := game.Board.SEE(*move(t, tt.move)); got != tt.want {
+				t.Errorf("SEE %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlunder(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		move    string
+		blunder bool
+		hanging []string
+	}{
+		{"safe", "4k3/8/8/8/3p4/8/8/1N2K3 w - -", "b1a3", false, nil},
+		{"piece under a pawn", "4k3/8/8/8/3p4/8/8/1N2K3 w - -", "b1c3", true, []string{"c3"}},
+		{"bad capture", "4k3/8/2p5/3p4/8/8/8/3RK3 w - -", "d1d5", true, []string{"d5"}},
+		{"piece left hanging", "6k1/5ppp/2p5/1B6/8/8/8/R3K3 w - -", "e1d1", true, []string{"b5"}},
+		{"mate", "6k1/5ppp/2p5/1B6/8/8/8/R3K3 w - -", "a1a8", false, nil},
+		{"defended", "4k3/8/5b2/8/8/8/1P6/1N2K3 w - -", "b1c3", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := fromFEN(t, tt.fen)
+			blunder, err := game.Blunder(*move(t, tt.move))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if blunder.IsBlunder() != tt.blunder || !slices.Equal(blunder.Hanging, tt.hanging) {
+				t.Errorf("got %+v", blunder)
+			}
+		})
+	}
+
+	game := fromFEN(t, "4k3/8/8/8/3p4/8/8/1N2K3 w - -")
+	if _, err := game.Blunder(*move(t, "b1b3")); err == nil {
+		t.Error("illegal move checked")
+	}
+}
@@ -0,0 +1,115 @@
+package board
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeControl of the game. Zero Base means no clock at all, unless the
+// game is a correspondence one.
+type TimeControl struct {
+	Base         time.Duration
+	Increment    time.Duration
+	DaysPerMove  int `json:",omitempty"` // correspondence: every move has this many days
+	VacationDays int `json:",omitempty"` // each player may add to their deadlines
+}
+
+func (tc TimeControl) IsTimed() bool {
+	return tc.Base > 0 || tc.IsCorrespondence()
+}
+
+func (tc TimeControl) IsCorrespondence() bool {
+	return tc.DaysPerMove > 0
+}
+
+// PerMove is the time for every move of a correspondence game.
+func (tc TimeControl) PerMove() time.Duration {
+	return time.Duration(tc.DaysPerMove) * Day
+}
+
+const Day = 24 * time.Hour
+
+func (tc TimeControl) String() string {
+	switch {
+	case tc.IsCorrespondence():
+		return fmt.Sprintf("%dd/move", tc.DaysPerMove)
+	case !tc.IsTimed():
+		return "∞"
+	}
+	return fmt.Sprintf("%d+%d", int(tc.Base.Minutes()), int(tc.Increment.Seconds()))
+}
+
+// Clock keeps remaining time of both sides. Only the side to move loses
+// time, and only while the clock is running.
+type Clock struct {
+	TimeControl
+	White   time.Duration
+	Black   time.Duration
+	Running bool
+	Since   time.Time // when the running side started thinking
+}
+
+func NewClock(tc TimeControl) Clock {
+	base := tc.Base
+	if tc.IsCorrespondence() {
+		base = tc.PerMove()
+	}
+	return Clock{TimeControl: tc, White: base, Black: base}
+}
+
+// Start runs the clock of the side to move from now.
+func (c *Clock) Start(now time.Time) {
+	if !c.IsTimed() {
+		return
+	}
+	c.Running = true
+	c.Since = now
+}
+
+// Stop freezes remaining time of the side to move.
+func (c *Clock) Stop(now time.Time, isBlack bool) {
+	if !c.Running {
+		return
+	}
+	*c.side(isBlack) = c.Remaining(now, isBlack)
+	c.Running = false
+}
+
+// Switch ends the turn of the given side: charges spent time, adds
+// increment and starts the opponent's clock. In correspondence games the
+// side gets the full time for its next move instead.
+func (c *Clock) Switch(now time.Time, isBlack bool) {
+	if !c.IsTimed() {
+		return
+	}
+	switch {
+	case c.IsCorrespondence():
+		*c.side(isBlack) = c.PerMove()
+	case c.Running:
+		*c.side(isBlack) = c.Remaining(now, isBlack) + c.Increment
+	}
+	c.Start(now)
+}
+
+// Extend adds time to the side, e.g. vacation days.
+func (c *Clock) Extend(isBlack bool, d time.Duration) {
+	*c.side(isBlack) += d
+}
+
+func (c Clock) Remaining(now time.Time, isBlack bool) time.Duration {
+	left := c.White
+	if isBlack {
+		left = c.Black
+	}
+	if c.Running {
+		left -= now.Sub(c.Since)
+	}
+	return max(left, 0)
+}
+
+func (c *Clock) side(isBlack bool) *time.Duration {
+	if isBlack {
+		return &c.Black
+	}
+	return &c.White
+}
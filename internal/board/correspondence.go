@@ -0,0 +1,24 @@
+package board
+
+import "time"
+
+// vacation days the side took.
+func (g *Game) vacation(isBlack bool) *int {
+	if isBlack {
+		return &g.BlackVacation
+	}
+	return &g.WhiteVacation
+}
+
+// VacationLeft is the number of vacation days the side may still take.
+func (g *Game) VacationLeft(isBlack bool) int {
+	return max(g.Clock.VacationDays-*g.vacation(isBlack), 0)
+}
+
+// Deadline of the side to move, false while its clock is stopped.
+func (g *Game) Deadline(now time.Time) (time.Time, bool) {
+	if !g.Clock.Running || g.IsEnded() {
+		return time.Time{}, false
+	}
+	return now.Add(g.TimeLeft(now, g.IsBlackTurn)), true
+}
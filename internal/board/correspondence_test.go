@@ -0,0 +1,53 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestCorrespondence(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	game, err := board.Rebuild([]board.Event{{Type: board.CREATED, At: start,
+		TimeControl: &board.TimeControl{DaysPerMove: 3, VacationDays: 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	moveAt := func(notation string, d time.Duration) {
+		t.Helper()
+		if err := game.Apply(board.Event{Type: board.MOVED, At: at(d), Move: move(t, notation)}); err != nil {
+			t.Fatalf("%s: %v", notation, err)
+		}
+	}
+	moveAt("e2e4", 0)
+	moveAt("e7e5", 2*board.Day)
+	// Time left from earlier moves does not add up.
+	if deadline, ok := game.Deadline(at(2 * board.Day)); !ok || !deadline.Equal(at(5*board.Day)) {
+		t.Errorf("deadline %v, want %v", deadline, at(5*board.Day))
+	}
+
+	if err := game.Apply(board.Event{Type: board.VACATION, At: at(3 * board.Day), Days: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if deadline, _ := game.Deadline(at(3 * board.Day)); !deadline.Equal(at(7 * board.Day)) {
+		t.Errorf("deadline with vacation %v, want %v", deadline, at(7*board.Day))
+	}
+	if err := game.Apply(board.Event{Type: board.VACATION, At: at(3 * board.Day), Days: 4}); !errors.Is(err, board.ErrNoVacation) {
+		t.Errorf("vacation over the limit: %v", err)
+	}
+	if left := game.VacationLeft(false); left != 3 {
+		t.Errorf("vacation left %d, want 3", left)
+	}
+
+	if err := game.Apply(board.Event{Type: board.FLAG_FELL, At: at(6 * board.Day)}); !errors.Is(err, board.ErrFlagNotFallen) {
+		t.Errorf("flag before the deadline: %v", err)
+	}
+	if err := game.Apply(board.Event{Type: board.FLAG_FELL, At: at(7 * board.Day)}); err != nil {
+		t.Fatal(err)
+	}
+	if game.Result != board.BLACK_WON || game.Termination != board.TIMEOUT {
+		t.Errorf("result %v %v", game.Result, game.Termination)
+	}
+}
@@ -0,0 +1,73 @@
+package board
+
+import (
+	"strings"
+	"ust_chess/internal/types"
+)
+
+// positionKey identifies the position for repetitions: placement of the
+// pieces, side to move, castling rights and the file of an en passant
+// capture if one can be made.
+func (g *Game) positionKey() string {
+	var key strings.Builder
+	for y := range 8 {
+		for x := range 8 {
+			piece := g.Board.GetCell(types.MustNewPos(x, y)).GetPiece()
+			switch {
+			case piece == nil:
+				key.WriteByte('.')
+			case piece.IsWhite():
+				key.WriteString("w" + piece.GetType().String())
+			default:
+				key.WriteString("b" + piece.GetType().String())
+			}
+		}
+	}
+	if g.IsBlackTurn {
+		key.WriteByte('b')
+	} else {
+		key.WriteByte('w')
+	}
+	key.WriteByte('0' + byte(g.Castling))
+	if file := g.enPassantFile(); file >= 0 {
+		key.WriteByte('e')
+		key.WriteByte('0' + byte(file))
+	}
+	return key.String()
+}
+
+// enPassantFile is the file a pawn of the side to move can take en
+// passant on, -1 if none can. A double step alone doesn't count.
+func (g *Game) enPassantFile() int {
+	file := g.Board.EnPassant()
+	if file < 0 {
+		return -1
+	}
+	for _, move := range g.Board.LegalMoves() {
+		piece := g.Board.GetCell(move.GetInitial()).GetPiece()
+		if piece.GetType() == types.PAWN && move.GetFinal().GetX() == file && move.GetInitial().GetX() != file &&
+			g.Board.GetCell(move.GetFinal()).GetPiece() == nil {
+			return file
+		}
+	}
+	return -1
+}
+
+// Repetitions of the current position, itself included.
+func (g *Game) Repetitions() int {
+	return g.Positions[g.positionKey()]
+}
+
+// CanClaim tells if a draw can be claimed for the reason now.
+func (g *Game) CanClaim(reason Termination) bool {
+	if g.IsEnded() {
+		return false
+	}
+	switch reason {
+	case REPETITION:
+		return g.Repetitions() >= 3
+	case FIFTY_MOVES:
+		return g.HalfmoveClock >= 100
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestDrawOffer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	offer := func(game *board.Game, typ board.EventType, isBlack bool) error {
+		return game.Apply(board.Event{Type: typ, At: start, IsBlack: isBlack})
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		game := newGame(t, start)
+		if err := offer(&game, board.DRAW_OFFERED, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := offer(&game, board.DRAW_ACCEPTED, false); !errors.Is(err, board.ErrNoDrawOffer) {
+			t.Errorf("own offer accepted: %v", err)
+		}
+		if err := offer(&game, board.DRAW_ACCEPTED, true); err != nil {
+			t.Fatal(err)
+		}
+		if game.Result != board.DRAW || game.Termination != board.AGREEMENT {
+			t.Errorf("result %v %v", game.Result, game.Termination)
+		}
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		game := newGame(t, start)
+		offer(&game, board.DRAW_OFFERED, false)
+		if err := offer(&game, board.DRAW_DECLINED, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := offer(&game, board.DRAW_ACCEPTED, true); !errors.Is(err, board.ErrNoDrawOffer) {
+			t.Errorf("declined offer accepted: %v", err)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		game := newGame(t, start)
+		offer(&game, board.DRAW_OFFERED, false)
+		play(t, &game, start, "g1f3", "g8f6")
+		if !game.DrawOffer.Made {
+			t.Fatal("offer expired before the offering side moved again")
+		}
+		play(t, &game, start, "f3g1")
+		if game.DrawOffer.Made {
+			t.Error("offer stands after the offering side moved again")
+		}
+	})
+}
+
+func TestDrawClaim(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	claim := func(game *board.Game, reason board.Termination) error {
+		return game.Apply(board.Event{Type: board.DRAW_CLAIMED, At: start, Reason: reason})
+	}
+	shuffle := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+
+	game := newGame(t, start)
+	play(t, &game, start, shuffle...)
+	if err := claim(&game, board.REPETITION); !errors.Is(err, board.ErrClaimRejected) {
+		t.Errorf("claim after two repetitions: %v", err)
+	}
+	play(t, &game, start, shuffle...)
+	if game.Repetitions() != 3 {
+		t.Fatalf("repetitions %d, want 3", game.Repetitions())
+	}
+	if err := claim(&game, board.FIFTY_MOVES); !errors.Is(err, board.ErrClaimRejected) {
+		t.Errorf("fifty-move claim after 8 moves: %v", err)
+	}
+	for range 23 {
+		play(t, &game, start, shuffle...)
+	}
+	if game.HalfmoveClock != 100 {
+		t.Fatalf("halfmove clock %d, want 100", game.HalfmoveClock)
+	}
+	if err := claim(&game, board.FIFTY_MOVES); err != nil {
+		t.Fatal(err)
+	}
+	if game.Result != board.DRAW || game.Termination != board.FIFTY_MOVES {
+		t.Errorf("result %v %v", game.Result, game.Termination)
+	}
+
+	// The first time the position comes d5 can be taken en passant, so it
+	// is not the same position.
+	game = newGame(t, start)
+	back := []string{"g1f3", "f6g8", "f3g1", "g8f6"}
+	play(t, &game, start, "e2e4", "g8f6", "e4e5", "d7d5")
+	play(t, &game, start, back...)
+	play(t, &game, start, back...)
+	if err := claim(&game, board.REPETITION); !errors.Is(err, board.ErrClaimRejected) {
+		t.Errorf("claim counting the position with en passant: %v", err)
+	}
+	play(t, &game, start, back...)
+	if err := claim(&game, board.REPETITION); err != nil {
+		t.Fatal(err)
+	}
+
+	// A double step no pawn can take doesn't change the position.
+	game = newGame(t, start)
+	play(t, &game, start, "g1f3", "d7d5", "f3g1", "g8f6", "g1f3", "f6g8")
+	if game.Repetitions() != 2 {
+		t.Errorf("repetitions %d after a double step nobody can take, want 2", game.Repetitions())
+	}
+
+	game = newGame(t, start)
+	play(t, &game, start, "e2e4")
+	play(t, &game, start, "g8f6", "g1f3")
+	if game.HalfmoveClock != 2 {
+		t.Errorf("halfmove clock %d after a pawn move, want 2", game.HalfmoveClock)
+	}
+}
@@ -0,0 +1,319 @@
+package board
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"ust_chess/internal/types"
+)
+
+var (
+	ErrBadEvent       = errors.New("bad event")
+	ErrNotCreated     = errors.New("game has no created event")
+	ErrFlagNotFallen  = errors.New("flag has not fallen")
+	ErrGameSuspended  = errors.New("game suspended, server is restarting")
+	ErrNoDrawOffer    = errors.New("no draw offer from the opponent")
+	ErrAlreadyOffered = errors.New("already offered")
+	ErrClaimRejected  = errors.New("draw claim rejected")
+	ErrNoTakeback     = errors.New("no takeback request from the opponent")
+	ErrBadTakeback    = errors.New("only your last move or your last full move can be taken back")
+	ErrNoPauseOffer   = errors.New("no pause offer from the opponent")
+	ErrNoAdjournOffer = errors.New("no adjournment offer from the opponent")
+	ErrNotAdjourned   = errors.New("game is not adjourned")
+	ErrAdjourned      = errors.New("game is adjourned")
+	ErrCantAbort      = errors.New("game can't be aborted once both sides moved")
+	ErrYourTurn       = errors.New("it is your turn, make a move instead")
+	ErrNoVacation     = errors.New("not enough vacation days left")
+)
+
+type EventType string
+
+const (
+	CREATED       EventType = "created"
+	MOVED         EventType = "move"
+	DRAW_OFFERED  EventType = "draw offered"
+	DRAW_ACCEPTED EventType = "draw accepted"
+	DRAW_DECLINED EventType = "draw declined"
+	DRAW_CLAIMED  EventType = "draw claimed"
+	RESIGNED      EventType = "resigned"
+	FLAG_FELL     EventType = "flag fall"
+	SUSPENDED     EventType = "suspended" // server went down
+	RESUMED       EventType = "resumed"   // server is back
+
+	TAKEBACK_OFFERED  EventType = "takeback offered"
+	TAKEBACK_ACCEPTED EventType = "takeback accepted"
+	TAKEBACK_DECLINED EventType = "takeback declined"
+
+	PAUSE_OFFERED    EventType = "pause offered" // to pause, or to go on if paused
+	PAUSE_ACCEPTED   EventType = "pause accepted"
+	PAUSE_DECLINED   EventType = "pause declined"
+	ADJOURN_OFFERED  EventType = "adjourn offered" // with the sealed move
+	ADJOURN_ACCEPTED EventType = "adjourn accepted"
+	ADJOURN_DECLINED EventType = "adjourn declined"
+	READY            EventType = "ready" // player is back for an adjourned game
+
+	WIN_CLAIMED EventType = "win claimed" // the opponent left
+	ABORTED     EventType = "aborted"     // a first move never came
+
+	QUEUED EventType = "moves queued" // premove or conditional moves, none to cancel
+
+	VACATION EventType = "vacation" // days added to the deadlines of the side
+
+	SETTLED EventType = "settled" // the server rated and saved the finished game
+)
+
+// Event is a change of the game. The state of a game is the result of
+// applying all its events in order, starting from CREATED.
+type Event struct {
+	Type        EventType
+	At          time.Time
+	IsBlack     bool         `json:",omitempty"` // side that acted or flagged
+	Move        *types.Move  `json:",omitempty"`
+	TimeControl *TimeControl `json:",omitempty"`
+	Reason      Termination  `json:",omitempty"` // of a draw claim
+	Plies       int          `json:",omitempty"` // moves to take back
+	Queued      Conditional  `json:",omitempty"`
+	Days        int          `json:",omitempty"` // of vacation
+}
+
+// Offer of one side to the other, e.g. a draw.
+type Offer struct {
+	Made    bool
+	IsBlack bool // made by black
+	Ply     int  // number of moves played when made
+	Plies   int  // moves to take back
+}
+
+// color of the side that made the offer, empty if there is none.
+func (o Offer) color() string {
+	switch {
+	case !o.Made:
+		return ""
+	case o.IsBlack:
+		return "black"
+	}
+	return "white"
+}
+
+// to tells if the offer is made to the side.
+func (o Offer) to(isBlack bool) bool {
+	return o.Made && o.IsBlack != isBlack
+}
+
+// Apply checks the event against the rules and changes the game. Nothing
+// changes when an error is returned. CREATED starts the game over.
+func (g *Game) Apply(event Event) error {
+	switch event.Type {
+	case CREATED:
+		if event.TimeControl == nil {
+			return errors.Join(ErrBadEvent, errors.New("no time control"))
+		}
+		*g = NewGame([]types.Piece{})
+		g.Clock = NewClock(*event.TimeControl)
+		return nil
+	case RESUMED:
+		if !g.IsSuspended {
+			return errors.Join(ErrBadEvent, errors.New("game is not suspended"))
+		}
+		g.IsSuspended = false
+		if len(g.History) > 0 && !g.IsEnded() {
+			g.Clock.Start(event.At)
+		}
+		return nil
+	case SETTLED:
+		if !g.IsEnded() || g.IsSettled {
+			return errors.Join(ErrBadEvent, errors.New("settles a finished game once"))
+		}
+		g.IsSettled = true
+		return nil
+	}
+
+	if g.IsSuspended {
+		return ErrGameSuspended
+	}
+	switch event.Type {
+	case MOVED:
+		if event.Move == nil {
+			return errors.Join(ErrBadEvent, errors.New("no move"))
+		}
+		if err := g.MakeMoveAt(*event.Move, event.At); err != nil {
+			return err
+		}
+		g.playQueued(*event.Move, event.At)
+		return nil
+	}
+
+	if g.IsEnded() {
+		return ErrGameEnded
+	}
+	switch event.Type {
+	case SUSPENDED:
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.IsSuspended = true
+	case DRAW_OFFERED:
+		if g.DrawOffer.Made {
+			return ErrAlreadyOffered
+		}
+		g.DrawOffer = Offer{Made: true, IsBlack: event.IsBlack, Ply: len(g.History)}
+	case DRAW_ACCEPTED:
+		if !g.DrawOffer.to(event.IsBlack) {
+			return ErrNoDrawOffer
+		}
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(DRAW, AGREEMENT)
+	case DRAW_DECLINED:
+		if !g.DrawOffer.to(event.IsBlack) {
+			return ErrNoDrawOffer
+		}
+		g.DrawOffer = Offer{}
+	case DRAW_CLAIMED:
+		// Only the server knows if the opponent left.
+		if event.Reason != ABANDONMENT && !g.CanClaim(event.Reason) {
+			return errors.Join(ErrClaimRejected, fmt.Errorf("%s", event.Reason))
+		}
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(DRAW, event.Reason)
+	case WIN_CLAIMED:
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(winner(event.IsBlack), ABANDONMENT)
+	case ABORTED:
+		if len(g.History) >= 2 {
+			return ErrCantAbort
+		}
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(NO_RESULT, NO_FIRST_MOVE)
+	case PAUSE_OFFERED:
+		if g.PauseOffer.Made {
+			return ErrAlreadyOffered
+		}
+		if g.IsAdjourned {
+			return ErrAdjourned
+		}
+		g.PauseOffer = Offer{Made: true, IsBlack: event.IsBlack, Ply: len(g.History)}
+	case PAUSE_ACCEPTED:
+		if !g.PauseOffer.to(event.IsBlack) {
+			return ErrNoPauseOffer
+		}
+		g.PauseOffer = Offer{}
+		g.setPause(!g.IsPause, event.At)
+	case PAUSE_DECLINED:
+		if !g.PauseOffer.to(event.IsBlack) {
+			return ErrNoPauseOffer
+		}
+		g.PauseOffer = Offer{}
+	case ADJOURN_OFFERED:
+		if g.AdjournOffer.Made {
+			return ErrAlreadyOffered
+		}
+		if g.IsAdjourned {
+			return ErrAdjourned
+		}
+		if event.Move == nil {
+			return errors.Join(ErrBadEvent, errors.New("no sealed move"))
+		}
+		if event.IsBlack != g.IsBlackTurn {
+			return errors.Join(ErrOpponentsTurn, errors.New("only the side to move seals a move"))
+		}
+		if err := g.checkMove(*event.Move); err != nil {
+			return err
+		}
+		g.AdjournOffer = Offer{Made: true, IsBlack: event.IsBlack, Ply: len(g.History)}
+		g.SealedMove = event.Move
+	case ADJOURN_ACCEPTED:
+		if !g.AdjournOffer.to(event.IsBlack) {
+			return ErrNoAdjournOffer
+		}
+		g.AdjournOffer, g.PauseOffer = Offer{}, Offer{}
+		g.setPause(true, event.At)
+		g.IsAdjourned = true
+	case ADJOURN_DECLINED:
+		if !g.AdjournOffer.to(event.IsBlack) {
+			return ErrNoAdjournOffer
+		}
+		g.AdjournOffer, g.SealedMove = Offer{}, nil
+	case READY:
+		if !g.IsAdjourned {
+			return ErrNotAdjourned
+		}
+		ready := &g.IsWhiteReady
+		if event.IsBlack {
+			ready = &g.IsBlackReady
+		}
+		if *ready {
+			return nil
+		}
+		*ready = true
+		if g.IsWhiteReady && g.IsBlackReady {
+			if err := g.resumeAdjourned(event.At); err != nil {
+				*ready = false
+				return err
+			}
+		}
+	case QUEUED:
+		if event.IsBlack == g.IsBlackTurn {
+			return ErrYourTurn
+		}
+		*g.queued(event.IsBlack) = event.Queued
+	case VACATION:
+		if !g.Clock.IsCorrespondence() || event.Days <= 0 {
+			return errors.Join(ErrBadEvent, errors.New("vacation days in a correspondence game only"))
+		}
+		used := g.vacation(event.IsBlack)
+		if *used+event.Days > g.Clock.VacationDays {
+			return ErrNoVacation
+		}
+		*used += event.Days
+		g.Clock.Extend(event.IsBlack, time.Duration(event.Days)*Day)
+	case TAKEBACK_OFFERED:
+		if g.IsPause {
+			return ErrGamePaused
+		}
+		if g.TakebackOffer.Made {
+			return ErrAlreadyOffered
+		}
+		if !g.CanTakeBack(event.IsBlack, event.Plies) {
+			return ErrBadTakeback
+		}
+		g.TakebackOffer = Offer{Made: true, IsBlack: event.IsBlack, Ply: len(g.History), Plies: event.Plies}
+	case TAKEBACK_ACCEPTED:
+		if !g.TakebackOffer.to(event.IsBlack) {
+			return ErrNoTakeback
+		}
+		if g.IsPause {
+			return ErrGamePaused
+		}
+		return g.takeBack(g.TakebackOffer, event.At)
+	case TAKEBACK_DECLINED:
+		if !g.TakebackOffer.to(event.IsBlack) {
+			return ErrNoTakeback
+		}
+		g.TakebackOffer = Offer{}
+	case RESIGNED:
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(winner(!event.IsBlack), RESIGNATION)
+	case FLAG_FELL:
+		if event.IsBlack != g.IsBlackTurn || !g.IsFlagged(event.At) {
+			return ErrFlagNotFallen
+		}
+		g.Clock.Stop(event.At, g.IsBlackTurn)
+		g.end(winner(!event.IsBlack), TIMEOUT)
+	default:
+		return errors.Join(ErrBadEvent, fmt.Errorf("type %q", event.Type))
+	}
+	return nil
+}
+
+// Rebuild makes the game from its events. Events before the last CREATED
+// belong to earlier games and change nothing.
+func Rebuild(events []Event) (Game, error) {
+	if len(events) == 0 || events[0].Type != CREATED {
+		return Game{}, ErrNotCreated
+	}
+	var game Game
+	for i, event := range events {
+		if err := game.Apply(event); err != nil {
+			return Game{}, errors.Join(fmt.Errorf("event %d %s", i+1, event.Type), err)
+		}
+	}
+	return game, nil
+}
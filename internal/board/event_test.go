@@ -0,0 +1,127 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+func move(t *testing.T, notation string) *types.Move {
+	t.Helper()
+	m, err := types.ParseMove(notation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func TestRebuild(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tc := board.TimeControl{Base: time.Minute, Increment: time.Second}
+	events := []board.Event{
+		{Type: board.CREATED, At: start, TimeControl: &tc},
+		{Type: board.MOVED, At: start.Add(5 * time.Second), Move: move(t, "e2e4")},
+		{Type: board.MOVED, At: start.Add(15 * time.Second), Move: move(t, "e7e5")},
+		{Type: board.MOVED, At: start.Add(20 * time.Second), Move: move(t, "d2d4")},
+	}
+	game, err := board.Rebuild(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.History) != 3 || !game.IsBlackTurn {
+		t.Fatalf("history %d, black to move %v", len(game.History), game.IsBlackTurn)
+	}
+	// Black thought for 10s and got the increment, white's first move was
+	// free and the second took 5s.
+	if white, black := game.Clock.White, game.Clock.Black; white != 56*time.Second || black != 51*time.Second {
+		t.Errorf("clocks %v %v, want 56s 51s", white, black)
+	}
+
+	t.Run("flag fall", func(t *testing.T) {
+		early := append(events, board.Event{Type: board.FLAG_FELL, At: start.Add(time.Minute), IsBlack: true})
+		if _, err := board.Rebuild(early); !errors.Is(err, board.ErrFlagNotFallen) {
+			t.Errorf("early flag: %v", err)
+		}
+		late := append(events, board.Event{Type: board.FLAG_FELL, At: start.Add(2 * time.Minute), IsBlack: true})
+		game, err := board.Rebuild(late)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.Result != board.WHITE_WON || game.Termination != board.TIMEOUT {
+			t.Errorf("result %v %v", game.Result, game.Termination)
+		}
+	})
+
+	t.Run("resignation", func(t *testing.T) {
+		game, err := board.Rebuild(append(events, board.Event{Type: board.RESIGNED, At: start.Add(time.Minute), IsBlack: false}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.Result != board.BLACK_WON || game.Termination != board.RESIGNATION {
+			t.Errorf("result %v %v", game.Result, game.Termination)
+		}
+		if _, err := board.Rebuild(append(events,
+			board.Event{Type: board.RESIGNED, At: start.Add(time.Minute)},
+			board.Event{Type: board.MOVED, At: start.Add(time.Minute), Move: move(t, "e5d4")},
+		)); !errors.Is(err, board.ErrGameEnded) {
+			t.Errorf("move after resignation: %v", err)
+		}
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		game, err := board.Rebuild(append(events,
+			board.Event{Type: board.SUSPENDED, At: start.Add(30 * time.Second)},
+			board.Event{Type: board.RESUMED, At: start.Add(time.Hour)},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Black thought 10s before and 5s after the server was down.
+		if left := game.TimeLeft(start.Add(time.Hour+5*time.Second), true); left != 36*time.Second {
+			t.Errorf("black has %v, want 36s", left)
+		}
+		if _, err := board.Rebuild(append(events,
+			board.Event{Type: board.SUSPENDED, At: start.Add(30 * time.Second)},
+			board.Event{Type: board.MOVED, At: start.Add(40 * time.Second), Move: move(t, "e5d4")},
+		)); !errors.Is(err, board.ErrGameSuspended) {
+			t.Errorf("move while suspended: %v", err)
+		}
+	})
+
+	t.Run("created starts over", func(t *testing.T) {
+		game, err := board.Rebuild(append(events, events[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(game.History) != 0 || game.IsBlackTurn {
+			t.Errorf("history %d after restart", len(game.History))
+		}
+	})
+
+	if _, err := board.Rebuild(events[1:]); !errors.Is(err, board.ErrNotCreated) {
+		t.Errorf("no created event: %v", err)
+	}
+}
+
+// play applies moves as events, the nth move of the game n seconds after
+// the start.
+func play(t *testing.T, game *board.Game, start time.Time, moves ...string) {
+	t.Helper()
+	for _, m := range moves {
+		at := start.Add(time.Duration(len(game.History)+1) * time.Second)
+		if err := game.Apply(board.Event{Type: board.MOVED, At: at, Move: move(t, m)}); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+}
+
+func newGame(t *testing.T, start time.Time) board.Game {
+	t.Helper()
+	game, err := board.Rebuild([]board.Event{{Type: board.CREATED, At: start, TimeControl: &board.TimeControl{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"time"
+	"ust_chess/internal/eval"
 	"ust_chess/internal/types"
 )
 
@@ -13,24 +14,54 @@ var (
 	ErrGameEnded        = errors.New("game ended")
 	ErrKingCheckedStill = errors.New("own king checked still")
 	ErrNoPieceToMove    = errors.New("no piece to move")
-	ErrIlligalMove      = errors.New("illigal move")
+	ErrIlligalMove      = types.ErrIllegalMove
 	ErrOpponentsTurn    = errors.New("opponent's turn")
+	ErrTimeIsUp         = errors.New("time is up")
 )
 
 type Game struct {
-	Board             types.Board
-	LastMovedPiece    *types.Piece
-	TurnNum           int
-	IsBlackTurn       bool
-	IsWhiteCantCastle bool
-	IsBlackCantCastle bool
-	IsKingChecked     bool
-	IsCheckmate       bool
-	IsPause           bool
-	EnPassantPawn     *types.Piece
-	LastMoveTime      time.Time
-	History           error
-	Error             string
+	Board          types.Board
+	LastMovedPiece *types.Piece
+	TurnNum        int
+	IsBlackTurn    bool
+	Castling       types.Castling // rights left
+	IsKingChecked  bool
+	IsCheckmate    bool
+	IsStalemate    bool
+	IsPause        bool
+	IsAdjourned    bool
+	IsWhiteReady   bool // back to resume the adjourned game
+	IsBlackReady   bool
+	SealedMove     *types.Move // played when the adjourned game resumes
+	PauseOffer     Offer
+	AdjournOffer   Offer
+	IsSuspended    bool // clocks stopped while the server is down
+	DrawOffer      Offer
+	TakebackOffer  Offer
+	WhiteTakebacks int // takebacks white was given
+	BlackTakebacks int
+	WhiteVacation  int // vacation days white took
+	BlackVacation  int
+	WhiteQueued    Conditional // moves white plays on its own once black moves
+	BlackQueued    Conditional
+	HalfmoveClock  int            // moves since the last capture or pawn move
+	Positions      map[string]int // times each position occurred
+	EnPassantPawn  *types.Piece
+	LastMoveTime   time.Time
+	History        []Record
+	Clock          Clock
+	Result         Result
+	Termination    Termination
+	IsSettled      bool // the server handled the end of the game
+	Error          string
+}
+
+// Record of a played move with clocks left after it.
+type Record struct {
+	Move      types.Move
+	WhiteLeft time.Duration
+	BlackLeft time.Duration
+	At        time.Time
 }
 
 var classic = []types.Piece{
@@ -92,51 +123,142 @@ var classic = []types.Piece{
     г) Проверить что линию атаки фигуры можно перекрыть фигурой кроме короля (если это не конь)
 */
 func (g *Game) MakeMove(move types.Move) error {
+	return g.MakeMoveAt(move, time.Now())
+}
+
+func (g *Game) MakeMoveAt(move types.Move, now time.Time) error {
 	if g.IsPause {
 		return ErrGamePaused
 	}
-	if g.IsCheckmate {
+	if g.IsCheckmate || g.IsEnded() {
 		return ErrGameEnded
 	}
+	if g.IsFlagged(now) {
+		return errors.Join(ErrGameEnded, ErrTimeIsUp)
+	}
+	isBlack := g.IsBlackTurn
+	move, err := g.applyMove(move)
+	if err != nil {
+		return err
+	}
+	// The offer stands for one turn of the opponent.
+	if g.DrawOffer.Made && g.DrawOffer.IsBlack == isBlack && len(g.History) > g.DrawOffer.Ply {
+		g.DrawOffer = Offer{}
+	}
+	g.TakebackOffer = Offer{}
+	g.AdjournOffer, g.SealedMove = Offer{}, nil
+
+	g.Clock.Switch(now, !g.IsBlackTurn)
+	g.LastMoveTime = now
+	g.History = append(g.History, Record{
+		Move:      move,
+		WhiteLeft: g.Clock.Remaining(now, false),
+		BlackLeft: g.Clock.Remaining(now, true),
+		At:        now,
+	})
+	switch {
+	case g.IsCheckmate:
+		g.end(winner(isBlack), CHECKMATE)
+	case g.IsStalemate:
+		g.end(DRAW, STALEMATE)
+	}
+	return nil
+}
+
+// applyMove checks the move against the rules and plays it. The move is
+// returned as played, e.g. with the piece a pawn was promoted to.
+func (g *Game) applyMove(move types.Move) (types.Move, error) {
 	piece := g.Board.GetCell(move.GetInitial()).GetPiece()
 	if piece == nil {
-		return errors.Join(ErrNoPieceToMove, fmt.Errorf("%s", move))
+		return move, errors.Join(ErrNoPieceToMove, fmt.Errorf("%s", move))
 	}
 	if piece.IsWhite() == g.IsBlackTurn {
-		return ErrOpponentsTurn
-	}
-	if err := piece.MakeMove(move, &g.Board); err != nil {
-		switch {
-		case errors.Is(err, types.ErrEnPassantMove):
-			g.EnPassantPawn = g.Board.GetCell(move.GetInitial()).GetPiece()
-		case errors.Is(err, types.ErrEnPassantTake):
-			pos := types.MustNewPos(0, 0)
-			piece := g.Board.GetCell(pos).GetPiece()
-			piece.Take()
-			piece = nil
-		case errors.Is(err, types.ErrCastleMove):
-			if err := checkForValidCastle(move, &g.Board); err != nil {
-				return errors.Join(ErrIlligalMove, err)
-			}
-		default:
-			return errors.Join(ErrIlligalMove, err)
+		return move, ErrOpponentsTurn
+	}
+	move, err := g.Board.Legal(move)
+	if err != nil {
+		// The piece tells better what is wrong with its move.
+		if reason := piece.MakeMove(move, &g.Board); reason != nil && !isSignal(reason) {
+			err = errors.Join(ErrIlligalMove, reason)
 		}
+		return move, err
 	}
 
-	g.Board.MakeMove(move)
-
+	g.Board.Make(move)
+	g.IsBlackTurn = g.Board.IsBlackTurn()
+	g.Castling = g.Board.Castling()
+	g.HalfmoveClock = g.Board.HalfmoveClock()
 	g.EnPassantPawn = nil
-	g.IsBlackTurn = !g.IsBlackTurn
+	if g.Board.EnPassant() >= 0 {
+		g.EnPassantPawn = g.Board.GetCell(move.GetFinal()).GetPiece()
+	}
+	g.setStatus()
+	g.Positions[g.positionKey()]++
+	return move, nil
+}
 
-	return nil
+// setStatus tells if the side to move is in check, mated or stalemated.
+func (g *Game) setStatus() {
+	g.IsKingChecked = g.Board.InCheck(!g.IsBlackTurn)
+	noMoves := len(g.Board.LegalMoves()) == 0
+	g.IsCheckmate = noMoves && g.IsKingChecked
+	g.IsStalemate = noMoves && !g.IsKingChecked
 }
 
-func checkForValidCastle(move types.Move, board *types.Board) error {
-	return nil
+// LegalMoves of the side to move.
+func (g *Game) LegalMoves() []types.Move {
+	return g.Board.LegalMoves()
 }
 
-func checkForValidEnpassantTake(move types.Move, board *types.Board) error {
-	return nil
+// isSignal tells if the error of a piece only asks the game to look at a
+// special move.
+func isSignal(err error) bool {
+	return errors.Is(err, types.ErrEnPassantMove) || errors.Is(err, types.ErrEnPassantTake) || errors.Is(err, types.ErrCastleMove)
+}
+
+func (g *Game) IsEnded() bool {
+	return g.Result != ONGOING
+}
+
+// IsFlagged tells if the side to move has run out of time. The game ends
+// only when a FLAG_FELL event is applied.
+func (g *Game) IsFlagged(now time.Time) bool {
+	return !g.IsEnded() && g.Clock.Running && g.Clock.Remaining(now, g.IsBlackTurn) <= 0
+}
+
+// TimeLeft on the clock of the side. Only the side to move is losing time.
+func (g *Game) TimeLeft(now time.Time, isBlack bool) time.Duration {
+	if isBlack != g.IsBlackTurn {
+		return max(*g.Clock.side(isBlack), 0)
+	}
+	return g.Clock.Remaining(now, isBlack)
+}
+
+func (g *Game) end(result Result, termination Termination) {
+	g.Result = result
+	g.Termination = termination
+	g.Clock.Running = false
+	g.DrawOffer = Offer{}
+	g.TakebackOffer = Offer{}
+	g.PauseOffer = Offer{}
+	g.AdjournOffer, g.SealedMove = Offer{}, nil
+	g.WhiteQueued, g.BlackQueued = nil, nil
+}
+
+// Replay builds the game from the recorded moves. Clocks are set to the
+// values of the last record and left stopped.
+func Replay(tc TimeControl, history []Record) (Game, error) {
+	game := NewGame([]types.Piece{})
+	game.Clock = NewClock(tc)
+	for i, record := range history {
+		if _, err := game.applyMove(record.Move); err != nil {
+			return Game{}, errors.Join(fmt.Errorf("move %d %s", i+1, record.Move.Notation()), err)
+		}
+		game.Clock.White, game.Clock.Black = record.WhiteLeft, record.BlackLeft
+		game.LastMoveTime = record.At
+		game.History = append(game.History, record)
+	}
+	return game, nil
 }
 
 type GameOutDto struct {
@@ -144,52 +266,159 @@ type GameOutDto struct {
 	IsKingChecked bool
 	IsCheckmate   bool
 	Board         [][]PieceOutDto
+	Moves         []string
+	Clock         ClockOutDto
+	DrawOffer     string // color of the side offering a draw
+	TakebackOffer string // color of the side asking for a takeback
+	TakebackPlies int
+	IsPause       bool
+	IsAdjourned   bool
+	PauseOffer    string // color of the side asking to pause or to go on
+	AdjournOffer  string // color of the side that sealed a move
+	WhiteReady    bool   // back to resume the adjourned game
+	BlackReady    bool
+	CanClaim      []ClaimOutDto
+	Eval          *eval.Evaluation // of the board, in analysis rooms
+	Result        string
+	Termination   string
 	Error         string
 }
 
+type ClaimOutDto struct {
+	Reason string // value for the claim form
+	Name   string
+}
+
+type ClockOutDto struct {
+	Timed   bool
+	Running bool
+	White   int64 // milliseconds left
+	Black   int64
+}
+
 type PieceOutDto struct {
-	T     string
-	White bool
-	Pos   string
+	T            string
+	White        bool
+	Pos          string
+	WhiteControl int // white pieces attacking the cell
+	BlackControl int
+	Attackers    int // pieces attacking the piece on the cell
+	Defenders    int // own pieces defending it
+}
+
+// Control of the cell: "white", "black", "contested" or "" if nobody
+// attacks it.
+func (p PieceOutDto) Control() string {
+	switch {
+	case p.WhiteControl > 0 && p.BlackControl > 0:
+		return "contested"
+	case p.WhiteControl > 0:
+		return "white"
+	case p.BlackControl > 0:
+		return "black"
+	}
+	return ""
+}
+
+// Hanging piece: attacked and not defended.
+func (p PieceOutDto) Hanging() bool {
+	return p.Attackers > 0 && p.Defenders == 0
+}
+
+// HideControl clears the attack counts of the cells.
+func (o *GameOutDto) HideControl() {
+	for y := range o.Board {
+		for x := range o.Board[y] {
+			cell := &o.Board[y][x]
+			cell.WhiteControl, cell.BlackControl, cell.Attackers, cell.Defenders = 0, 0, 0, 0
+		}
+	}
 }
 
 func (g *Game) GetForRender() GameOutDto {
+	now := time.Now()
 	pieces := make([][]PieceOutDto, 8)
 	for y := range 8 {
 		pieces[y] = make([]PieceOutDto, 8)
 		for x := range 8 {
-			raw_piece := g.Board.GetCell(types.MustNewPos(x, y)).GetPiece()
+			cell := g.Board.GetCell(types.MustNewPos(x, y))
+			raw_piece := cell.GetPiece()
 			if raw_piece != nil {
 				pieces[y][x] = PieceOutDto{
 					T:     raw_piece.GetType().String(),
 					White: raw_piece.IsWhite(),
 					Pos:   raw_piece.GetPosition().String(),
 				}
-				continue
+			} else {
+				pieces[y][x] = PieceOutDto{
+					T:     " ",
+					White: false,
+					Pos:   types.MustNewPos(x, y).String(),
+				}
 			}
-			pieces[y][x] = PieceOutDto{
-				T:     " ",
-				White: false,
-				Pos:   types.MustNewPos(x, y).String(),
+			for _, attacker := range cell.GetTargetedBy() {
+				if attacker.IsWhite() {
+					pieces[y][x].WhiteControl++
+				} else {
+					pieces[y][x].BlackControl++
+				}
+				switch {
+				case raw_piece == nil:
+				case attacker.IsWhite() == raw_piece.IsWhite():
+					pieces[y][x].Defenders++
+				default:
+					pieces[y][x].Attackers++
+				}
 			}
 		}
 	}
-	return GameOutDto{
+	moves := make([]string, len(g.History))
+	for i, record := range g.History {
+		moves[i] = record.Move.Notation()
+	}
+	out := GameOutDto{
 		IsBlackTurn:   g.IsBlackTurn,
 		IsKingChecked: g.IsKingChecked,
 		IsCheckmate:   g.IsCheckmate,
 		Board:         pieces,
-		Error:         g.Error,
+		Moves:         moves,
+		Clock: ClockOutDto{
+			Timed:   g.Clock.IsTimed(),
+			Running: g.Clock.Running,
+			White:   g.TimeLeft(now, false).Milliseconds(),
+			Black:   g.TimeLeft(now, true).Milliseconds(),
+		},
+		Termination: g.Termination.String(),
+		Error:       g.Error,
+	}
+	out.DrawOffer = g.DrawOffer.color()
+	out.TakebackOffer, out.TakebackPlies = g.TakebackOffer.color(), g.TakebackOffer.Plies
+	out.IsPause, out.IsAdjourned = g.IsPause, g.IsAdjourned
+	out.PauseOffer, out.AdjournOffer = g.PauseOffer.color(), g.AdjournOffer.color()
+	out.WhiteReady, out.BlackReady = g.IsWhiteReady, g.IsBlackReady
+	for _, reason := range []Termination{REPETITION, FIFTY_MOVES} {
+		if g.CanClaim(reason) {
+			out.CanClaim = append(out.CanClaim, ClaimOutDto{Reason: reason.Code(), Name: reason.String()})
+		}
+	}
+	if g.IsEnded() {
+		out.Result = g.Result.String()
 	}
+	return out
 }
 
-/*
-Проверять состояние игры на шах и мат сразу при создании.
-*/
+// NewGame with the pieces, white to move. No pieces mean the classic
+// setup. Castling is allowed for kings and rooks on their starting cells.
 func NewGame(pieces []types.Piece) Game {
-	board, err := types.GetBoard(classic)
+	if len(pieces) == 0 {
+		pieces = classic
+	}
+	board, err := types.GetBoard(pieces)
 	if err != nil {
 		panic(err)
 	}
-	return Game{Board: board}
+	game := Game{Board: board, Castling: board.Castling(), Positions: make(map[string]int)}
+	game.setStatus()
+	game.Positions[game.positionKey()]++
+	return game
 }
@@ -0,0 +1,90 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+// fromFEN builds a game with the pieces of the FEN, white to move.
+func fromFEN(t *testing.T, fen string) board.Game {
+	t.Helper()
+	b, err := types.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pieces []types.Piece
+	for x := range 8 {
+		for y := range 8 {
+			if piece := b.GetCell(types.MustNewPos(x, y)).GetPiece(); piece != nil {
+				pieces = append(pieces, *piece)
+			}
+		}
+	}
+	return board.NewGame(pieces)
+}
+
+func TestSpecialMoves(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("castling moves the rook", func(t *testing.T) {
+		game := newGame(t, start)
+		play(t, &game, start, "e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6", "e1g1")
+		rook := game.Board.GetCell(types.MustNewPos(2, 0)).GetPiece()
+		if rook == nil || rook.GetType() != types.ROOK || game.Board.GetCell(types.MustNewPos(0, 0)).GetPiece() != nil {
+			t.Error("rook not on f1")
+		}
+		if game.Castling&(types.WHITE_KINGSIDE|types.WHITE_QUEENSIDE) != 0 {
+			t.Errorf("white keeps castling rights %b", game.Castling)
+		}
+	})
+
+	t.Run("en passant", func(t *testing.T) {
+		game := newGame(t, start)
+		play(t, &game, start, "e2e4", "a7a6", "e4e5", "d7d5", "e5d6")
+		if game.Board.GetCell(types.MustNewPos(4, 4)).GetPiece() != nil {
+			t.Error("pawn taken en passant still on d5")
+		}
+	})
+
+	t.Run("promotion", func(t *testing.T) {
+		game := fromFEN(t, "7k/P7/8/8/8/8/8/K7 w - -")
+		if err := game.MakeMoveAt(*move(t, "a7a8n"), start); err != nil {
+			t.Fatal(err)
+		}
+		if piece := game.Board.GetCell(types.MustNewPos(7, 7)).GetPiece(); piece == nil || piece.GetType() != types.KNIGHT {
+			t.Errorf("promoted to %v", piece)
+		}
+		if notation := game.History[0].Move.Notation(); notation != "a7a8n" {
+			t.Errorf("recorded %s", notation)
+		}
+	})
+
+	t.Run("pinned pawn can't move", func(t *testing.T) {
+		game := newGame(t, start)
+		play(t, &game, start, "e2e4", "e7e5", "d1h5")
+		if err := game.MakeMoveAt(*move(t, "f7f6"), start); !errors.Is(err, types.ErrKingInCheck) {
+			t.Errorf("pinned pawn moved: %v", err)
+		}
+	})
+
+	t.Run("checkmate ends the game", func(t *testing.T) {
+		game := newGame(t, start)
+		play(t, &game, start, "f2f3", "e7e5", "g2g4", "d8h4")
+		if game.Result != board.BLACK_WON || game.Termination != board.CHECKMATE {
+			t.Errorf("result %v %v", game.Result, game.Termination)
+		}
+	})
+
+	t.Run("stalemate ends the game", func(t *testing.T) {
+		game := fromFEN(t, "7k/8/6Q1/8/8/8/8/K7 w - -")
+		if err := game.MakeMoveAt(*move(t, "g6f7"), start); err != nil {
+			t.Fatal(err)
+		}
+		if game.Result != board.DRAW || game.Termination != board.STALEMATE {
+			t.Errorf("result %v %v", game.Result, game.Termination)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package board
+
+import (
+	"time"
+	"ust_chess/internal/types"
+)
+
+// setPause stops the clock while paused and runs it again after.
+func (g *Game) setPause(pause bool, now time.Time) {
+	g.IsPause = pause
+	if pause {
+		g.Clock.Stop(now, g.IsBlackTurn)
+		return
+	}
+	if len(g.History) > 0 {
+		g.Clock.Start(now)
+	}
+}
+
+// checkMove tells if the move is legal now without playing it.
+func (g *Game) checkMove(move types.Move) error {
+	_, err := g.Board.Legal(move)
+	return err
+}
+
+// resumeAdjourned plays the sealed move once both players are back. The
+// clock was stopped, so the opponent's clock starts from now.
+func (g *Game) resumeAdjourned(now time.Time) error {
+	g.IsPause = false
+	sealed := *g.SealedMove
+	if err := g.MakeMoveAt(sealed, now); err != nil {
+		g.IsPause = true
+		return err
+	}
+	g.IsAdjourned, g.IsWhiteReady, g.IsBlackReady = false, false, false
+	g.playQueued(sealed, now)
+	return nil
+}
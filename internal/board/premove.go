@@ -0,0 +1,131 @@
+package board
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"ust_chess/internal/types"
+)
+
+var ErrConflictingReply = errors.New("different replies queued to the same move")
+
+// AnyMove keys the reply to whatever the opponent plays, i.e. a premove.
+const AnyMove = "*"
+
+// Conditional moves a player queued: the reply to each expected move of the
+// opponent by its notation, and the moves queued after the reply.
+type Conditional map[string]*Branch
+
+type Branch struct {
+	Reply types.Move
+	Then  Conditional `json:",omitempty"`
+}
+
+// Premove queues the move as the reply to any move of the opponent.
+func Premove(move types.Move) Conditional {
+	return Conditional{AnyMove: {Reply: move}}
+}
+
+// ParseConditional reads lines of moves alternating between the opponent
+// and the player, starting with the opponent, e.g. "e7e5 g1f3 b8c6 f1c4".
+// Lines with the same start share the branch. AnyMove stands for any move
+// of the opponent.
+func ParseConditional(lines []string) (Conditional, error) {
+	tree := Conditional{}
+	for _, line := range lines {
+		moves := strings.Fields(line)
+		if len(moves) == 0 {
+			continue
+		}
+		if len(moves)%2 != 0 {
+			return nil, errors.Join(ErrBadEvent, fmt.Errorf("no reply to the last move of %q", line))
+		}
+		node := tree
+		for i := 0; i < len(moves); i += 2 {
+			key := moves[i]
+			if key != AnyMove {
+				expected, err := types.ParseMove(key)
+				if err != nil {
+					return nil, err
+				}
+				key = expected.Notation()
+			}
+			reply, err := types.ParseMove(moves[i+1])
+			if err != nil {
+				return nil, err
+			}
+			branch, ok := node[key]
+			if !ok {
+				branch = &Branch{Reply: reply, Then: Conditional{}}
+				node[key] = branch
+			} else if branch.Reply != reply {
+				return nil, errors.Join(ErrConflictingReply, errors.New(key))
+			}
+			node = branch.Then
+		}
+	}
+	return tree, nil
+}
+
+// Next finds the reply to the move of the opponent and the moves queued
+// after it.
+func (c Conditional) Next(move types.Move) (types.Move, Conditional, bool) {
+	branch, ok := c[move.Notation()]
+	if !ok {
+		branch, ok = c[AnyMove]
+	}
+	if !ok {
+		return types.Move{}, nil, false
+	}
+	return branch.Reply, branch.Then, true
+}
+
+// Lines lists the queued moves the way ParseConditional reads them.
+func (c Conditional) Lines() []string {
+	var lines []string
+	for key, branch := range c {
+		line := key + " " + branch.Reply.Notation()
+		if len(branch.Then) == 0 {
+			lines = append(lines, line)
+			continue
+		}
+		for _, rest := range branch.Then.Lines() {
+			lines = append(lines, line+" "+rest)
+		}
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// queued moves of the side.
+func (g *Game) queued(isBlack bool) *Conditional {
+	if isBlack {
+		return &g.BlackQueued
+	}
+	return &g.WhiteQueued
+}
+
+// playQueued answers the move with the reply the side to move queued, and
+// so on while replies are queued. A reply that is not legal any more
+// drops the queue.
+func (g *Game) playQueued(move types.Move, now time.Time) {
+	for {
+		queued := g.queued(g.IsBlackTurn)
+		reply, rest, ok := queued.Next(move)
+		if !ok {
+			*queued = nil
+			return
+		}
+		if err := g.MakeMoveAt(reply, now); err != nil {
+			*queued = nil
+			return
+		}
+		if g.IsEnded() {
+			return
+		}
+		*queued = rest
+		move = reply
+	}
+}
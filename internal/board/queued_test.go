@@ -0,0 +1,97 @@
+package board_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestQueuedMoves(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	queue := func(t *testing.T, game *board.Game, isBlack bool, lines ...string) {
+		t.Helper()
+		queued, err := board.ParseConditional(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Apply(board.Event{Type: board.QUEUED, At: start, IsBlack: isBlack, Queued: queued}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("premove", func(t *testing.T) {
+		game := newGame(t, start)
+		if err := game.Apply(board.Event{Type: board.QUEUED, At: start, IsBlack: true, Queued: board.Premove(*move(t, "e7e5"))}); err != nil {
+			t.Fatal(err)
+		}
+		play(t, &game, start, "e2e4")
+		if len(game.History) != 2 || game.History[1].Move.Notation() != "e7e5" || game.BlackQueued != nil {
+			t.Errorf("history %v, queued %v", game.History, game.BlackQueued)
+		}
+	})
+
+	t.Run("illegal premove dropped", func(t *testing.T) {
+		game := newGame(t, start)
+		queue(t, &game, true, "* e7e4")
+		play(t, &game, start, "e2e4")
+		if len(game.History) != 1 || game.BlackQueued != nil {
+			t.Errorf("history %v, queued %v", game.History, game.BlackQueued)
+		}
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		game := newGame(t, start)
+		queue(t, &game, true, "e2e4 e7e5 g1f3 b8c6", "d2d4 d7d5")
+		if err := game.Apply(board.Event{Type: board.QUEUED, At: start, Queued: board.Premove(*move(t, "e2e4"))}); !errors.Is(err, board.ErrYourTurn) {
+			t.Errorf("queue on own turn: %v", err)
+		}
+		play(t, &game, start, "e2e4")
+		if want := []string{"g1f3 b8c6"}; !slices.Equal(game.BlackQueued.Lines(), want) {
+			t.Errorf("queued %q, want %q", game.BlackQueued.Lines(), want)
+		}
+		play(t, &game, start, "g1f3")
+		var moves []string
+		for _, record := range game.History {
+			moves = append(moves, record.Move.Notation())
+		}
+		if want := []string{"e2e4", "e7e5", "g1f3", "b8c6"}; !slices.Equal(moves, want) {
+			t.Errorf("moves %q, want %q", moves, want)
+		}
+		if len(game.BlackQueued) != 0 {
+			t.Errorf("queue left: %v", game.BlackQueued)
+		}
+	})
+
+	t.Run("both sides", func(t *testing.T) {
+		game := newGame(t, start)
+		queue(t, &game, true, "e2e4 e7e5 g1f3 b8c6")
+		play(t, &game, start, "d2d4")
+		if len(game.History) != 1 || game.BlackQueued != nil {
+			t.Fatalf("unexpected reply: %v", game.History)
+		}
+		queue(t, &game, false, "d7d5 c2c4 e7e6 b1c3")
+		play(t, &game, start, "d7d5")
+		if len(game.History) != 3 {
+			t.Errorf("history %v", game.History)
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		if _, err := board.ParseConditional([]string{"e2e4 e7e5", "e2e4 c7c5"}); !errors.Is(err, board.ErrConflictingReply) {
+			t.Errorf("conflicting replies: %v", err)
+		}
+		if _, err := board.ParseConditional([]string{"e2e4 e7e5 g1f3"}); err == nil {
+			t.Error("line without the last reply accepted")
+		}
+		lines := []string{"d2d4 d7d5", "e2e4 e7e5 f1c4 g8f6", "e2e4 e7e5 g1f3 b8c6"}
+		queued, err := board.ParseConditional(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(queued.Lines(), lines) {
+			t.Errorf("lines %q, want %q", queued.Lines(), lines)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package board
+
+type Result uint8
+
+const (
+	ONGOING Result = iota
+	WHITE_WON
+	BLACK_WON
+	DRAW
+	NO_RESULT // aborted game, as if never played
+)
+
+func (r Result) String() string {
+	switch r {
+	case WHITE_WON:
+		return "1-0"
+	case BLACK_WON:
+		return "0-1"
+	case DRAW:
+		return "½-½"
+	case NO_RESULT:
+		return "—"
+	default:
+		return "*"
+	}
+}
+
+// Termination tells why the game ended.
+type Termination uint8
+
+const (
+	NOT_TERMINATED Termination = iota
+	TIMEOUT
+	RESIGNATION
+	AGREEMENT
+	REPETITION
+	FIFTY_MOVES
+	ABANDONMENT
+	NO_FIRST_MOVE
+	CHECKMATE
+	STALEMATE
+)
+
+func (t Termination) String() string {
+	switch t {
+	case TIMEOUT:
+		return "time forfeit"
+	case RESIGNATION:
+		return "resignation"
+	case AGREEMENT:
+		return "agreement"
+	case REPETITION:
+		return "threefold repetition"
+	case FIFTY_MOVES:
+		return "fifty-move rule"
+	case ABANDONMENT:
+		return "abandonment"
+	case NO_FIRST_MOVE:
+		return "aborted, no first move"
+	case CHECKMATE:
+		return "checkmate"
+	case STALEMATE:
+		return "stalemate"
+	default:
+		return ""
+	}
+}
+
+// Code is a short name of the draw claim reason for forms and APIs.
+func (t Termination) Code() string {
+	switch t {
+	case REPETITION:
+		return "repetition"
+	case FIFTY_MOVES:
+		return "fifty"
+	default:
+		return ""
+	}
+}
+
+// ClaimReason is the termination of a draw claim by its code.
+func ClaimReason(code string) (Termination, bool) {
+	for _, reason := range []Termination{REPETITION, FIFTY_MOVES} {
+		if reason.Code() == code {
+			return reason, true
+		}
+	}
+	return NOT_TERMINATED, false
+}
+
+// winner returns the result where the given side wins.
+func winner(isBlack bool) Result {
+	if isBlack {
+		return BLACK_WON
+	}
+	return WHITE_WON
+}
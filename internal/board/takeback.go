@@ -0,0 +1,46 @@
+package board
+
+import "time"
+
+// CanTakeBack tells if the side may ask to take back the plies: 1 for its
+// last move while the opponent thinks, 2 for its last full move on its own
+// turn.
+func (g *Game) CanTakeBack(isBlack bool, plies int) bool {
+	switch plies {
+	case 1:
+		return len(g.History) >= 1 && g.IsBlackTurn != isBlack
+	case 2:
+		return len(g.History) >= 2 && g.IsBlackTurn == isBlack
+	}
+	return false
+}
+
+// Takebacks the side was given in this game.
+func (g *Game) Takebacks(isBlack bool) int {
+	if isBlack {
+		return g.BlackTakebacks
+	}
+	return g.WhiteTakebacks
+}
+
+// takeBack undoes the moves of the offer by playing the game again without
+// them. Clocks get the values they had back then and the side to move
+// thinks from now.
+func (g *Game) takeBack(offer Offer, now time.Time) error {
+	game, err := Replay(g.Clock.TimeControl, g.History[:len(g.History)-offer.Plies])
+	if err != nil {
+		return err
+	}
+	game.WhiteTakebacks, game.BlackTakebacks = g.WhiteTakebacks, g.BlackTakebacks
+	game.WhiteVacation, game.BlackVacation = g.WhiteVacation, g.BlackVacation
+	if offer.IsBlack {
+		game.BlackTakebacks++
+	} else {
+		game.WhiteTakebacks++
+	}
+	if len(game.History) > 0 {
+		game.Clock.Start(now)
+	}
+	*g = game
+	return nil
+}
@@ -0,0 +1,75 @@
+package board_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+)
+
+func TestTakeback(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tc := board.TimeControl{Base: time.Minute}
+	events := []board.Event{
+		{Type: board.CREATED, At: start, TimeControl: &tc},
+		{Type: board.MOVED, At: start.Add(5 * time.Second), Move: move(t, "e2e4")},
+		{Type: board.MOVED, At: start.Add(15 * time.Second), Move: move(t, "e7e5")},
+		{Type: board.MOVED, At: start.Add(20 * time.Second), Move: move(t, "g1f3")},
+	}
+	at := start.Add(25 * time.Second)
+
+	t.Run("last move", func(t *testing.T) {
+		game, err := board.Rebuild(events)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Apply(board.Event{Type: board.TAKEBACK_OFFERED, At: at, Plies: 2}); !errors.Is(err, board.ErrBadTakeback) {
+			t.Errorf("full move on the opponent's turn: %v", err)
+		}
+		if err := game.Apply(board.Event{Type: board.TAKEBACK_OFFERED, At: at, Plies: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Apply(board.Event{Type: board.TAKEBACK_ACCEPTED, At: at}); !errors.Is(err, board.ErrNoTakeback) {
+			t.Errorf("own request accepted: %v", err)
+		}
+		if err := game.Apply(board.Event{Type: board.TAKEBACK_ACCEPTED, At: at, IsBlack: true}); err != nil {
+			t.Fatal(err)
+		}
+		if len(game.History) != 2 || game.IsBlackTurn || game.WhiteTakebacks != 1 {
+			t.Fatalf("history %d, black to move %v, takebacks %d", len(game.History), game.IsBlackTurn, game.WhiteTakebacks)
+		}
+		// White thinks again with the full minute from the takeback on.
+		now := at.Add(5 * time.Second)
+		if white, black := game.TimeLeft(now, false), game.TimeLeft(now, true); white != 55*time.Second || black != 50*time.Second {
+			t.Errorf("clocks %v %v, want 55s 50s", white, black)
+		}
+		// The taken back move can be played again.
+		play(t, &game, start.Add(time.Minute), "g1f3")
+	})
+
+	t.Run("full move", func(t *testing.T) {
+		game, err := board.Rebuild(append(events,
+			board.Event{Type: board.TAKEBACK_OFFERED, At: at, IsBlack: true, Plies: 2},
+			board.Event{Type: board.TAKEBACK_ACCEPTED, At: at},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(game.History) != 1 || !game.IsBlackTurn || game.BlackTakebacks != 1 {
+			t.Errorf("history %d, black to move %v, takebacks %d", len(game.History), game.IsBlackTurn, game.BlackTakebacks)
+		}
+	})
+
+	t.Run("declined by a move", func(t *testing.T) {
+		game, err := board.Rebuild(append(events,
+			board.Event{Type: board.TAKEBACK_OFFERED, At: at, Plies: 1},
+			board.Event{Type: board.MOVED, At: at, Move: move(t, "b8c6")},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.TakebackOffer.Made {
+			t.Error("request stands after the opponent moved")
+		}
+	})
+}
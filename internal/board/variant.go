@@ -0,0 +1,17 @@
+package board
+
+// Variant of chess rules. Only classic chess is played so far.
+type Variant uint8
+
+const (
+	CLASSIC Variant = iota
+)
+
+func (v Variant) String() string {
+	switch v {
+	case CLASSIC:
+		return "classic"
+	default:
+		return "???"
+	}
+}
@@ -0,0 +1,359 @@
+// Package engine searches chess positions for the best move: alpha-beta
+// with iterative deepening, quiescence search over captures and a
+// transposition table.
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+var (
+	ErrNoMoves   = errors.New("no legal moves")
+	ErrGameEnded = errors.New("game is over")
+)
+
+const (
+	Mate     = 100000 // score of a mate on the board, less the plies to it
+	infinity = Mate + 1
+	maxPly   = 64
+	maxTable = 1 << 20 // entries the transposition table keeps
+)
+
+// Options limit the search. Zero values mean no limit, but one of Depth,
+// Nodes or Time should be set.
+type Options struct {
+	Depth      int           // plies searched
+	Nodes      int           // positions visited
+	Time       time.Duration // budget of one search
+	Randomness int           // up to this many centipawns added to each move, for variety
+	Report     func(Result)  // called after each finished iteration
+}
+
+// Levels are the strengths offered to players, from the weakest.
+var Levels = []Options{
+	{Depth: 1, Randomness: 300},
+	{Depth: 2, Randomness: 150},
+	{Depth: 3, Randomness: 60},
+	{Depth: 4, Randomness: 25, Time: 2 * time.Second},
+	{Depth: 6, Randomness: 10, Time: 3 * time.Second},
+	{Time: 5 * time.Second},
+}
+
+// Level returns options of the level from 1 to len(Levels), the closest
+// one for levels out of range.
+func Level(level int) Options {
+	return Levels[min(max(level, 1), len(Levels))-1]
+}
+
+// Result of a search. Score is in centipawns for the side to move.
+type Result struct {
+	Move  types.Move
+	Score int
+	Depth int // of the last finished iteration
+	Nodes int
+}
+
+type bound uint8
+
+const (
+	EXACT bound = iota
+	LOWER
+	UPPER
+)
+
+type entry struct {
+	depth int
+	score int
+	bound bound
+	move  types.Move
+}
+
+// Engine is not safe for concurrent use. It keeps the transposition table
+// between searches.
+type Engine struct {
+	options  Options
+	done     <-chan struct{} // of the context of the search
+	table    map[uint64]entry
+	random   *rand.Rand
+	nodes    int
+	deadline time.Time
+	stopped  bool
+	path     []uint64 // hashes of positions from the root, for repetitions
+}
+
+func New(options Options) *Engine {
+	return &Engine{
+		options: options,
+		table:   make(map[uint64]entry),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Best searches the position of the game.
+func (e *Engine) Best(ctx context.Context, game *board.Game) (Result, error) {
+	if game.IsEnded() {
+		return Result{}, ErrGameEnded
+	}
+	b := game.Board.Clone()
+	return e.Search(ctx, &b)
+}
+
+type rootMove struct {
+	move  types.Move
+	noise int
+}
+
+// Search deepens the search one ply at a time until a limit is hit or the
+// context is done and returns the best move of the last finished
+// iteration. The board is played on and left as it was.
+func (e *Engine) Search(ctx context.Context, b *types.Board) (Result, error) {
+	legal := b.LegalMoves()
+	if len(legal) == 0 {
+		return Result{}, ErrNoMoves
+	}
+	e.nodes, e.stopped, e.path, e.done = 0, false, e.path[:0], ctx.Done()
+	start := time.Now()
+	e.deadline = time.Time{}
+	if e.options.Time > 0 {
+		e.deadline = start.Add(e.options.Time)
+	}
+	if len(e.table) > maxTable {
+		clear(e.table)
+	}
+
+	moves := make([]rootMove, len(legal))
+	e.order(b, legal, types.Move{})
+	for i, move := range legal {
+		moves[i] = rootMove{move: move}
+		if e.options.Randomness > 0 {
+			moves[i].noise = e.random.Intn(e.options.Randomness + 1)
+		}
+	}
+	best := Result{Move: moves[0].move}
+	e.path = append(e.path, hash(b))
+	for depth := 1; depth <= maxPly && (e.options.Depth == 0 || depth <= e.options.Depth); depth++ {
+		alpha, found := -infinity, -1
+		for i, root := range moves {
+			undo := b.Make(root.move)
+			score := -e.search(b, depth-1, -infinity, root.noise-alpha, 1) + root.noise
+			b.Unmake(undo)
+			if e.stopped {
+				break
+			}
+			if score > alpha {
+				alpha, found = score, i
+			}
+		}
+		// A stopped iteration still counts if it found a move better
+		// than the one of the previous iteration, which goes first.
+		if found >= 0 {
+			best = Result{Move: moves[found].move, Score: alpha, Depth: depth}
+			if e.stopped {
+				best.Depth = depth - 1
+			}
+			first := moves[found]
+			copy(moves[1:found+1], moves[:found])
+			moves[0] = first
+			if e.options.Report != nil && !e.stopped {
+				e.options.Report(Result{Move: best.Move, Score: best.Score, Depth: best.Depth, Nodes: e.nodes})
+			}
+		}
+		if e.stopped || alpha > Mate-maxPly || alpha < -Mate+maxPly {
+			break
+		}
+		// The next iteration takes several times longer.
+		if !e.deadline.IsZero() && time.Since(start) > e.options.Time/2 {
+			break
+		}
+	}
+	best.Nodes = e.nodes
+	return best, nil
+}
+
+// search returns the score of the position for the side to move within
+// alpha and beta.
+func (e *Engine) search(b *types.Board, depth, alpha, beta, ply int) int {
+	if e.stop() {
+		return 0
+	}
+	if b.HalfmoveClock() >= 100 {
+		return 0
+	}
+	key := hash(b)
+	if slices.Contains(e.path, key) {
+		return 0
+	}
+	inCheck := b.InCheck(!b.IsBlackTurn())
+	if inCheck {
+		depth++
+	}
+	if depth <= 0 || ply >= maxPly {
+		return e.quiesce(b, alpha, beta, ply)
+	}
+
+	cached, ok := e.table[key]
+	if ok && cached.depth >= depth {
+		score := fromTable(cached.score, ply)
+		switch {
+		case cached.bound == EXACT,
+			cached.bound == LOWER && score >= beta,
+			cached.bound == UPPER && score <= alpha:
+			return score
+		}
+	}
+
+	moves := b.LegalMoves()
+	if len(moves) == 0 {
+		if inCheck {
+			return -Mate + ply
+		}
+		return 0
+	}
+	e.order(b, moves, cached.move)
+
+	e.path = append(e.path, key)
+	defer func() { e.path = e.path[:len(e.path)-1] }()
+	best, bestMove, origAlpha := -infinity, moves[0], alpha
+	for _, move := range moves {
+		undo := b.Make(move)
+		score := -e.search(b, depth-1, -beta, -alpha, ply+1)
+		b.Unmake(undo)
+		if e.stopped {
+			return 0
+		}
+		if score > best {
+			best, bestMove = score, move
+		}
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+
+	stored := entry{depth: depth, score: toTable(best, ply), bound: EXACT, move: bestMove}
+	switch {
+	case best <= origAlpha:
+		stored.bound = UPPER
+	case best >= beta:
+		stored.bound = LOWER
+	}
+	e.table[key] = stored
+	return best
+}
+
+// quiesce plays captures and promotions until the position is quiet, so
+// the evaluation doesn't stop in the middle of an exchange.
+func (e *Engine) quiesce(b *types.Board, alpha, beta, ply int) int {
+	if e.stop() {
+		return 0
+	}
+	standPat := evaluate(b)
+	if standPat >= beta || ply >= maxPly {
+		return standPat
+	}
+	alpha = max(alpha, standPat)
+
+	moves := slices.DeleteFunc(b.LegalMoves(), func(move types.Move) bool {
+		return !isCapture(b, move) && move.Promotion == 0
+	})
+	e.order(b, moves, types.Move{})
+	for _, move := range moves {
+		undo := b.Make(move)
+		score := -e.quiesce(b, -beta, -alpha, ply+1)
+		b.Unmake(undo)
+		if e.stopped {
+			return 0
+		}
+		if score >= beta {
+			return score
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+// stop counts the node and tells if a limit is hit.
+func (e *Engine) stop() bool {
+	e.nodes++
+	switch {
+	case e.stopped:
+	case e.options.Nodes > 0 && e.nodes >= e.options.Nodes:
+		e.stopped = true
+	case e.nodes%1024 != 0:
+	case !e.deadline.IsZero() && time.Now().After(e.deadline):
+		e.stopped = true
+	default:
+		select {
+		case <-e.done:
+			e.stopped = true
+		default:
+		}
+	}
+	return e.stopped
+}
+
+// order puts the move from the table first, then captures of the most
+// valuable pieces by the least valuable ones, then the rest.
+func (e *Engine) order(b *types.Board, moves []types.Move, first types.Move) {
+	scores := make(map[types.Move]int, len(moves))
+	for _, move := range moves {
+		score := move.Promotion.Value()
+		switch {
+		case move == first:
+			score = infinity
+		case isCapture(b, move):
+			attacker := figureOn(b, move.GetInitial())
+			victim := figureOn(b, move.GetFinal())
+			score += 10*max(victim.Value(), types.PAWN.Value()) - attacker.Value()
+		}
+		scores[move] = score
+	}
+	slices.SortStableFunc(moves, func(a, b types.Move) int {
+		return scores[b] - scores[a]
+	})
+}
+
+func isCapture(b *types.Board, move types.Move) bool {
+	if figureOn(b, move.GetFinal()) != 0 {
+		return true
+	}
+	// en passant
+	return figureOn(b, move.GetInitial()) == types.PAWN &&
+		move.GetInitial().GetX() != move.GetFinal().GetX()
+}
+
+// figureOn the cell, zero if it is empty.
+func figureOn(b *types.Board, pos types.Position) types.Figure {
+	if piece := b.GetCell(pos).GetPiece(); piece != nil {
+		return piece.GetType()
+	}
+	return 0
+}
+
+// toTable makes mate scores relative to the position, so they stay right
+// when it's reached by another path. fromTable undoes it.
+func toTable(score, ply int) int {
+	switch {
+	case score > Mate-maxPly:
+		return score + ply
+	case score < -Mate+maxPly:
+		return score - ply
+	}
+	return score
+}
+
+func fromTable(score, ply int) int {
+	switch {
+	case score > Mate-maxPly:
+		return score - ply
+	case score < -Mate+maxPly:
+		return score + ply
+	}
+	return score
+}
@@ -0,0 +1,118 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/engine"
+	"ust_chess/internal/types"
+)
+
+func game(t *testing.T, moves ...string) board.Game {
+	t.Helper()
+	g := board.NewGame(nil)
+	for _, notation := range moves {
+		move, err := types.ParseMove(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.MakeMove(move); err != nil {
+			t.Fatalf("%s: %v", notation, err)
+		}
+	}
+	return g
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []string
+		want  string
+	}{
+		{"mate in one", []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5", "g8f6"}, "h5f7"},
+		{"takes hanging queen", []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5g2"}, "f1g2"},
+		{"escapes mate", []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := game(t, tt.moves...)
+			result, err := engine.New(engine.Options{Depth: 3}).Best(context.Background(), &g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && result.Move.Notation() != tt.want {
+				t.Errorf("played %s, want %s", result.Move.Notation(), tt.want)
+			}
+			if err := g.MakeMove(result.Move); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" && g.IsEnded() {
+				t.Errorf("%s lost to mate", result.Move.Notation())
+			}
+		})
+	}
+}
+
+func TestMateScore(t *testing.T) {
+	g := game(t, "f2f3", "e7e5", "g2g4")
+	result, err := engine.New(engine.Options{Depth: 2}).Best(context.Background(), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Move.Notation() != "d8h4" || result.Score != engine.Mate-1 {
+		t.Errorf("%s scored %d", result.Move.Notation(), result.Score)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	g := game(t)
+	result, err := engine.New(engine.Options{Nodes: 500}).Best(context.Background(), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Nodes > 500 {
+		t.Errorf("%d nodes searched", result.Nodes)
+	}
+
+	start := time.Now()
+	if _, err := engine.New(engine.Options{Time: 100 * time.Millisecond}).Best(context.Background(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if spent := time.Since(start); spent > 200*time.Millisecond {
+		t.Errorf("searched for %v", spent)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	result, err = engine.New(engine.Options{}).Best(ctx, &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent := time.Since(start); spent > 200*time.Millisecond || result.Move == (types.Move{}) {
+		t.Errorf("searched for %v, played %s", spent, result.Move.Notation())
+	}
+}
+
+func TestRandomness(t *testing.T) {
+	g := game(t)
+	played := make(map[string]bool)
+	for range 20 {
+		result, err := engine.New(engine.Options{Depth: 1, Randomness: 1000}).Best(context.Background(), &g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		played[result.Move.Notation()] = true
+	}
+	if len(played) < 2 {
+		t.Errorf("always played %v", played)
+	}
+}
+
+func TestEndedGame(t *testing.T) {
+	g := game(t, "f2f3", "e7e5", "g2g4", "d8h4")
+	if _, err := engine.New(engine.Level(1)).Best(context.Background(), &g); err == nil {
+		t.Error("searched a finished game")
+	}
+}
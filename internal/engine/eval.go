@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"ust_chess/internal/eval"
+	"ust_chess/internal/types"
+)
+
+// evaluate scores material and placement of the pieces in centipawns for
+// the side to move, by the tables of the evaluation bar.
+func evaluate(b *types.Board) int {
+	material := 0
+	for x := range 8 {
+		for y := range 8 {
+			if figure := figureOn(b, types.MustNewPos(x, y)); figure != types.PAWN {
+				material += figure.Value()
+			}
+		}
+	}
+	endgame := material < eval.EndgameMaterial
+
+	score := 0
+	for x := range 8 {
+		for y := range 8 {
+			piece := b.GetCell(types.MustNewPos(x, y)).GetPiece()
+			if piece == nil {
+				continue
+			}
+			value := piece.GetType().Value() + eval.Placement(piece, endgame)
+			if piece.IsWhite() == b.IsBlackTurn() {
+				value = -value
+			}
+			score += value
+		}
+	}
+	return score
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"math/rand"
+	"ust_chess/internal/types"
+)
+
+// Zobrist keys: a random number per piece on a square, per castling
+// rights, en passant file and side to move. Piece keys are laid out like
+// the bitboards of the board.
+var (
+	pieceKeys     [2][6][64]uint64
+	castlingKeys  [16]uint64
+	enPassantKeys [8]uint64
+	blackKey      uint64
+)
+
+func init() {
+	random := rand.New(rand.NewSource(1))
+	for color := range pieceKeys {
+		for figure := range pieceKeys[color] {
+			for square := range pieceKeys[color][figure] {
+				pieceKeys[color][figure][square] = random.Uint64()
+			}
+		}
+	}
+	for i := range castlingKeys {
+		castlingKeys[i] = random.Uint64()
+	}
+	for i := range enPassantKeys {
+		enPassantKeys[i] = random.Uint64()
+	}
+	blackKey = random.Uint64()
+}
+
+// hash of the board, from the bitboards: only the squares with pieces are
+// visited.
+func hash(b *types.Board) uint64 {
+	var key uint64
+	bitboards := b.Bitboards()
+	for color := range bitboards.Figures {
+		for figure, pieces := range bitboards.Figures[color] {
+			for pieces != 0 {
+				key ^= pieceKeys[color][figure][pieces.Pop()]
+			}
+		}
+	}
+	key ^= castlingKeys[b.Castling()&15]
+	if b.EnPassant() >= 0 {
+		key ^= enPassantKeys[b.EnPassant()]
+	}
+	if b.IsBlackTurn() {
+		key ^= blackKey
+	}
+	return key
+}
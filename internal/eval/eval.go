@@ -0,0 +1,97 @@
+// Package eval scores a board without a search: material, piece-square
+// tables, mobility, king safety and pawn structure. Scores are in
+// centipawns, positive when white is better.
+package eval
+
+import (
+	"fmt"
+	"math"
+	"ust_chess/internal/types"
+)
+
+// Evaluation of a board with the score of each term.
+type Evaluation struct {
+	Total      int
+	Material   int
+	Placement  int // piece-square tables
+	Mobility   int
+	KingSafety int
+	Pawns      int // pawn structure
+}
+
+type Term struct {
+	Name  string
+	Score int
+}
+
+// Terms in the order they are shown.
+func (e Evaluation) Terms() []Term {
+	return []Term{
+		{"material", e.Material},
+		{"placement", e.Placement},
+		{"mobility", e.Mobility},
+		{"king safety", e.KingSafety},
+		{"pawns", e.Pawns},
+	}
+}
+
+// String of the total in pawns, e.g. "+1.25".
+func (e Evaluation) String() string {
+	return fmt.Sprintf("%+.2f", float64(e.Total)/100)
+}
+
+// WhiteShare is the part of an evaluation bar in percent that goes to
+// white: 50 for equal chances, near 100 when white is winning.
+func (e Evaluation) WhiteShare() int {
+	return int(math.Round(100 / (1 + math.Exp(-float64(e.Total)/250))))
+}
+
+// EndgameMaterial: with less material than this, pieces other than pawns
+// and kings of both sides together, kings leave their shelter.
+const EndgameMaterial = 2600
+
+// square is a piece with where it stands and its rank from the side of
+// the piece, 0 to 7.
+type square struct {
+	piece *types.Piece
+	x, y  int // board coordinates
+	rank  int
+}
+
+// Evaluate scores the board.
+func Evaluate(board *types.Board) Evaluation {
+	var squares []square
+	nonPawn := 0
+	for x := range 8 {
+		for y := range 8 {
+			piece := board.GetCell(types.MustNewPos(x, y)).GetPiece()
+			if piece == nil {
+				continue
+			}
+			rank := y
+			if !piece.IsWhite() {
+				rank = 7 - y
+			}
+			squares = append(squares, square{piece, x, y, rank})
+			if piece.GetType() != types.PAWN {
+				nonPawn += piece.GetType().Value()
+			}
+		}
+	}
+	endgame := nonPawn < EndgameMaterial
+
+	var e Evaluation
+	for _, sq := range squares {
+		sign := 1
+		if !sq.piece.IsWhite() {
+			sign = -1
+		}
+		e.Material += sign * sq.piece.GetType().Value()
+		e.Placement += sign * Placement(sq.piece, endgame)
+		e.Mobility += sign * mobility(board, sq)
+	}
+	e.KingSafety = kingSafety(board, squares, true, endgame) - kingSafety(board, squares, false, endgame)
+	e.Pawns = pawnStructure(squares, true) - pawnStructure(squares, false)
+	e.Total = e.Material + e.Placement + e.Mobility + e.KingSafety + e.Pawns
+	return e
+}
@@ -0,0 +1,100 @@
+package eval_test
+
+import (
+	"testing"
+	"ust_chess/internal/eval"
+	"ust_chess/internal/types"
+)
+
+func evaluate(t *testing.T, fen string) eval.Evaluation {
+	t.Helper()
+	b, err := types.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eval.Evaluate(&b)
+}
+
+func TestSymmetry(t *testing.T) {
+	if e := evaluate(t, types.StartFEN); e != (eval.Evaluation{}) {
+		t.Errorf("start position scored %+v", e)
+	}
+	// The same positions with colors swapped and the board flipped.
+	pairs := [][2]string{
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -", "rnbqkb1r/pppp1ppp/5n2/4p3/4P3/2N5/PPPP1PPP/R1BQKBNR w KQkq -"},
+		{"6k1/5ppp/8/8/1P6/8/P4PPP/6K1 w - -", "6k1/p4ppp/8/1p6/8/8/5PPP/6K1 w - -"},
+	}
+	for _, pair := range pairs {
+		white, black := evaluate(t, pair[0]), evaluate(t, pair[1])
+		if white.Total != -black.Total || white.Pawns != -black.Pawns || white.KingSafety != -black.KingSafety {
+			t.Errorf("%+v and %+v", white, black)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		term   func(eval.Evaluation) int
+		better string // fen that should score more on the term
+	}{
+		{
+			name:   "centralized knight",
+			fen:    "4k3/8/8/8/8/8/8/N3K3 w - -",
+			term:   func(e eval.Evaluation) int { return e.Placement + e.Mobility },
+			better: "4k3/8/8/8/3N4/8/8/4K3 w - -",
+		},
+		{
+			name:   "castled king",
+			fen:    "rnbq1rk1/pppppppp/8/8/8/8/PPP2PPP/RNBQK2R w - -",
+			term:   func(e eval.Evaluation) int { return e.KingSafety },
+			better: "rnbq1rk1/pppppppp/8/8/8/8/PPP2PPP/RNBQ1RK1 w - -",
+		},
+		{
+			name:   "advanced passed pawn",
+			fen:    "4k3/8/8/8/8/8/P7/4K3 w - -",
+			term:   func(e eval.Evaluation) int { return e.Pawns },
+			better: "4k3/8/8/P7/8/8/8/4K3 w - -",
+		},
+		{
+			name:   "doubled pawns",
+			fen:    "4k3/pp6/8/8/8/P7/P7/4K3 w - -",
+			term:   func(e eval.Evaluation) int { return e.Pawns },
+			better: "4k3/pp6/8/8/8/8/PP6/4K3 w - -",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worse, better := evaluate(t, tt.fen), evaluate(t, tt.better)
+			if tt.term(better) <= tt.term(worse) {
+				t.Errorf("%+v is not better than %+v", better, worse)
+			}
+		})
+	}
+
+	e := evaluate(t, "rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -")
+	if e.Material != 320 {
+		t.Errorf("a knight up is %d", e.Material)
+	}
+	sum := 0
+	for _, term := range e.Terms() {
+		sum += term.Score
+	}
+	if sum != e.Total {
+		t.Errorf("terms add up to %d, total %d", sum, e.Total)
+	}
+}
+
+func TestWhiteShare(t *testing.T) {
+	for _, tt := range []struct{ total, min, max int }{
+		{0, 50, 50},
+		{100, 55, 65},
+		{-1000, 0, 5},
+		{1000, 95, 100},
+	} {
+		if share := (eval.Evaluation{Total: tt.total}).WhiteShare(); share < tt.min || share > tt.max {
+			t.Errorf("%d: %d%%", tt.total, share)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package eval
+
+import (
+	"ust_chess/internal/types"
+)
+
+// Piece-square tables from white's side, rank 8 on top and the "a" file on
+// the left, after the simplified evaluation function of Tomasz
+// Michniewski.
+var tables = map[types.Figure][8][8]int{
+	types.PAWN: {
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{50, 50, 50, 50, 50, 50, 50, 50},
+		{10, 10, 20, 30, 30, 20, 10, 10},
+		{5, 5, 10, 25, 25, 10, 5, 5},
+		{0, 0, 0, 20, 20, 0, 0, 0},
+		{5, -5, -10, 0, 0, -10, -5, 5},
+		{5, 10, 10, -20, -20, 10, 10, 5},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+	},
+	types.KNIGHT: {
+		{-50, -40, -30, -30, -30, -30, -40, -50},
+		{-40, -20, 0, 0, 0, 0, -20, -40},
+		{-30, 0, 10, 15, 15, 10, 0, -30},
+		{-30, 5, 15, 20, 20, 15, 5, -30},
+		{-30, 0, 15, 20, 20, 15, 0, -30},
+		{-30, 5, 10, 15, 15, 10, 5, -30},
+		{-40, -20, 0, 5, 5, 0, -20, -40},
+		{-50, -40, -30, -30, -30, -30, -40, -50},
+	},
+	types.BISHOP: {
+		{-20, -10, -10, -10, -10, -10, -10, -20},
+		{-10, 0, 0, 0, 0, 0, 0, -10},
+		{-10, 0, 5, 10, 10, 5, 0, -10},
+		{-10, 5, 5, 10, 10, 5, 5, -10},
+		{-10, 0, 10, 10, 10, 10, 0, -10},
+		{-10, 10, 10, 10, 10, 10, 10, -10},
+		{-10, 5, 0, 0, 0, 0, 5, -10},
+		{-20, -10, -10, -10, -10, -10, -10, -20},
+	},
+	types.ROOK: {
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{5, 10, 10, 10, 10, 10, 10, 5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{0, 0, 0, 5, 5, 0, 0, 0},
+	},
+	types.QUEEN: {
+		{-20, -10, -10, -5, -5, -10, -10, -20},
+		{-10, 0, 0, 0, 0, 0, 0, -10},
+		{-10, 0, 5, 5, 5, 5, 0, -10},
+		{-5, 0, 5, 5, 5, 5, 0, -5},
+		{0, 0, 5, 5, 5, 5, 0, -5},
+		{-10, 5, 5, 5, 5, 5, 0, -10},
+		{-10, 0, 5, 0, 0, 0, 0, -10},
+		{-20, -10, -10, -5, -5, -10, -10, -20},
+	},
+	types.KING: {
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-20, -30, -30, -40, -40, -30, -30, -20},
+		{-10, -20, -20, -20, -20, -20, -20, -10},
+		{20, 20, 0, 0, 0, 0, 20, 20},
+		{20, 30, 10, 0, 0, 10, 30, 20},
+	},
+}
+
+var kingEndgame = [8][8]int{
+	{-50, -40, -30, -20, -20, -30, -40, -50},
+	{-30, -20, -10, 0, 0, -10, -20, -30},
+	{-30, -10, 20, 30, 30, 20, -10, -30},
+	{-30, -10, 30, 40, 40, 30, -10, -30},
+	{-30, -10, 30, 40, 40, 30, -10, -30},
+	{-30, -10, 20, 30, 30, 20, -10, -30},
+	{-30, -30, 0, 0, 0, 0, -30, -30},
+	{-50, -30, -30, -30, -30, -30, -30, -50},
+}
+
+// Placement is the bonus of the piece from the tables for the cell it
+// stands on. The engine scores placement with it too.
+func Placement(piece *types.Piece, endgame bool) int {
+	table := tables[piece.GetType()]
+	if endgame && piece.GetType() == types.KING {
+		table = kingEndgame
+	}
+	x, rank := piece.GetPosition().GetX(), piece.GetPosition().GetY()
+	if !piece.IsWhite() {
+		rank = 7 - rank
+	}
+	return table[7-rank][7-x]
+}
+
+// Centipawns per square a piece can go to.
+var mobilityWeights = map[types.Figure]int{
+	types.KNIGHT: 4,
+	types.BISHOP: 5,
+	types.ROOK:   2,
+	types.QUEEN:  1,
+}
+
+func onBoard(x, y int) bool {
+	return x >= 0 && x < 8 && y >= 0 && y < 8
+}
+
+// mobility counts cells the piece attacks that are empty or hold an enemy,
+// from the attacks the board keeps.
+func mobility(board *types.Board, sq square) int {
+	weight := mobilityWeights[sq.piece.GetType()]
+	if weight == 0 {
+		return 0
+	}
+	count := 0
+	for _, pos := range sq.piece.GetTargetedCells() {
+		if other := board.GetCell(pos).GetPiece(); other == nil || other.IsWhite() != sq.piece.IsWhite() {
+			count++
+		}
+	}
+	return weight * count
+}
+
+const (
+	shieldBonus     = 10 // per pawn in front of the king
+	openFilePenalty = 15 // per file next to the king without own pawns
+	attackPenalty   = 6  // per enemy attack on the squares around the king
+)
+
+// kingSafety of the side: a pawn shield, no open files and few attacks
+// around the king. It doesn't count in the endgame.
+func kingSafety(board *types.Board, squares []square, white bool, endgame bool) int {
+	if endgame {
+		return 0
+	}
+	var king *square
+	for i := range squares {
+		if squares[i].piece.GetType() == types.KING && squares[i].piece.IsWhite() == white {
+			king = &squares[i]
+		}
+	}
+	if king == nil {
+		return 0
+	}
+	forward := 1
+	if !white {
+		forward = -1
+	}
+	score := 0
+	for dx := -1; dx <= 1; dx++ {
+		x := king.x + dx
+		if !onBoard(x, 0) {
+			continue
+		}
+		for dy := 1; dy <= 2; dy++ {
+			if !onBoard(x, king.y+dy*forward) {
+				continue
+			}
+			piece := board.GetCell(types.MustNewPos(x, king.y+dy*forward)).GetPiece()
+			if piece != nil && piece.GetType() == types.PAWN && piece.IsWhite() == white {
+				score += shieldBonus
+				break
+			}
+		}
+		if !hasPawn(squares, x, white) {
+			score -= openFilePenalty
+		}
+	}
+	// The king attacks the cells around it.
+	for _, pos := range king.piece.GetTargetedCells() {
+		for _, attacker := range board.GetCell(pos).GetTargetedBy() {
+			if attacker.IsWhite() != white {
+				score -= attackPenalty
+			}
+		}
+	}
+	return score
+}
+
+func hasPawn(squares []square, x int, white bool) bool {
+	for _, sq := range squares {
+		if sq.x == x && sq.piece.GetType() == types.PAWN && sq.piece.IsWhite() == white {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	doubledPenalty  = 15
+	isolatedPenalty = 15
+)
+
+// passedBonus by the rank of the pawn from its side.
+var passedBonus = [8]int{0, 5, 10, 20, 35, 60, 100, 0}
+
+// pawnStructure of the side: doubled and isolated pawns cost, passed ones
+// are worth more the further they are.
+func pawnStructure(squares []square, white bool) int {
+	var files [8]int
+	for _, sq := range squares {
+		if sq.piece.GetType() == types.PAWN && sq.piece.IsWhite() == white {
+			files[sq.x]++
+		}
+	}
+	score := 0
+	for x, count := range files {
+		if count > 1 {
+			score -= doubledPenalty * (count - 1)
+		}
+		if count > 0 && (x == 0 || files[x-1] == 0) && (x == 7 || files[x+1] == 0) {
+			score -= isolatedPenalty * count
+		}
+	}
+	for _, sq := range squares {
+		if sq.piece.GetType() == types.PAWN && sq.piece.IsWhite() == white && passed(squares, sq) {
+			score += passedBonus[sq.rank]
+		}
+	}
+	return score
+}
+
+// passed tells if no enemy pawn can stop the pawn on its file or the ones
+// next to it.
+func passed(squares []square, pawn square) bool {
+	for _, sq := range squares {
+		if sq.piece.GetType() != types.PAWN || sq.piece.IsWhite() == pawn.piece.IsWhite() {
+			continue
+		}
+		if sq.x < pawn.x-1 || sq.x > pawn.x+1 {
+			continue
+		}
+		// ranks of both from the side of the pawn
+		if 7-sq.rank > pawn.rank {
+			return false
+		}
+	}
+	return true
+}
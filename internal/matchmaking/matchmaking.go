@@ -0,0 +1,225 @@
+// Package matchmaking pairs players waiting for a game with the same time
+// control and variant. Allowed rating gap widens the longer a player waits.
+package matchmaking
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"ust_chess/internal/board"
+)
+
+var ErrAlreadyQueued = errors.New("already in queue")
+
+// Clock is the source of time of the service. Tests use a fake one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Pool is a queue of players who want the same kind of game.
+type Pool struct {
+	TimeControl board.TimeControl
+	Variant     board.Variant
+}
+
+type Ticket struct {
+	UserID      int
+	Rating      int
+	Provisional bool // rating is a guess so far
+	Pool        Pool
+	Joined      time.Time
+}
+
+// Match of two tickets. The player who waited longer plays white.
+type Match struct {
+	Pool  Pool
+	White Ticket
+	Black Ticket
+}
+
+type Options struct {
+	Interval     time.Duration // how often waiting players are re-checked
+	InitialGap   int           // rating gap allowed right after joining
+	GapPerSecond int           // gap growth for every second of waiting
+	MaxGap       int
+	// Provisional ratings can be far from the real strength, so such
+	// players start with a wider gap.
+	ProvisionalGap int
+}
+
+var DefaultOptions = Options{
+	Interval:       time.Second,
+	InitialGap:     50,
+	GapPerSecond:   10,
+	MaxGap:         600,
+	ProvisionalGap: 300,
+}
+
+type Service struct {
+	mu      sync.Mutex
+	clock   Clock
+	options Options
+	onMatch func(Match)
+	queues  map[Pool][]Ticket
+}
+
+// New creates the service. onMatch is called for every pair found, outside
+// of the service lock. Nil clock means real time.
+func New(clock Clock, options Options, onMatch func(Match)) *Service {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Service{
+		clock:   clock,
+		options: options,
+		onMatch: onMatch,
+		queues:  make(map[Pool][]Ticket),
+	}
+}
+
+// Run re-checks queues every Interval until the context is done.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.options.Interval):
+			s.pairAll()
+		}
+	}
+}
+
+// Join puts the player in the queue of the ticket's pool and tries to pair
+// them right away. Joined time is set by the service.
+func (s *Service) Join(ticket Ticket) error {
+	s.mu.Lock()
+	for _, queue := range s.queues {
+		for _, waiting := range queue {
+			if waiting.UserID == ticket.UserID {
+				s.mu.Unlock()
+				return ErrAlreadyQueued
+			}
+		}
+	}
+	ticket.Joined = s.clock.Now()
+	s.queues[ticket.Pool] = append(s.queues[ticket.Pool], ticket)
+	s.mu.Unlock()
+	s.pairAll()
+	return nil
+}
+
+// Cancel removes the player from any queue. Returns false if they weren't
+// waiting, e.g. already matched.
+func (s *Service) Cancel(userID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pool, queue := range s.queues {
+		for i, ticket := range queue {
+			if ticket.UserID == userID {
+				s.queues[pool] = append(queue[:i:i], queue[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Waiting returns the ticket of the player if they are queued.
+func (s *Service) Waiting(userID int) (Ticket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queue := range s.queues {
+		for _, ticket := range queue {
+			if ticket.UserID == userID {
+				return ticket, true
+			}
+		}
+	}
+	return Ticket{}, false
+}
+
+// Gap is the rating difference the ticket accepts at the moment.
+func (s *Service) Gap(ticket Ticket, now time.Time) int {
+	waited := int(now.Sub(ticket.Joined) / time.Second)
+	initial := s.options.InitialGap
+	if ticket.Provisional {
+		initial = max(initial, s.options.ProvisionalGap)
+	}
+	return min(initial+waited*s.options.GapPerSecond, s.options.MaxGap)
+}
+
+func (s *Service) pairAll() {
+	s.mu.Lock()
+	now := s.clock.Now()
+	var matches []Match
+	for pool := range s.queues {
+		matches = append(matches, s.pair(pool, now)...)
+	}
+	s.mu.Unlock()
+	for _, match := range matches {
+		s.onMatch(match)
+	}
+}
+
+// pair goes through the queue from the longest waiting player and gives
+// each one the closest rated opponent both of them accept. Caller holds
+// the lock.
+func (s *Service) pair(pool Pool, now time.Time) []Match {
+	queue := s.queues[pool]
+	sort.SliceStable(queue, func(i, j int) bool { return queue[i].Joined.Before(queue[j].Joined) })
+	var (
+		matches []Match
+		paired  = make([]bool, len(queue))
+	)
+	for i, ticket := range queue {
+		if paired[i] {
+			continue
+		}
+		best, bestDiff := -1, 0
+		for j := i + 1; j < len(queue); j++ {
+			if paired[j] {
+				continue
+			}
+			diff := abs(ticket.Rating - queue[j].Rating)
+			if diff > s.Gap(ticket, now) || diff > s.Gap(queue[j], now) {
+				continue
+			}
+			if best == -1 || diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		paired[i], paired[best] = true, true
+		matches = append(matches, Match{Pool: pool, White: ticket, Black: queue[best]})
+	}
+
+	rest := queue[:0]
+	for i, ticket := range queue {
+		if !paired[i] {
+			rest = append(rest, ticket)
+		}
+	}
+	if len(rest) == 0 {
+		delete(s.queues, pool)
+	} else {
+		s.queues[pool] = rest
+	}
+	return matches
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,284 @@
+package matchmaking_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/matchmaking"
+)
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{c.now.Add(d), ch})
+	return ch
+}
+
+// Advance moves time forward one tick at a time, waiting for the service
+// loop to ask for the next tick before moving on.
+func (c *fakeClock) Advance(t *testing.T, d, tick time.Duration) {
+	t.Helper()
+	for passed := time.Duration(0); passed < d; passed += tick {
+		c.waitForLoop(t)
+		c.mu.Lock()
+		c.now = c.now.Add(tick)
+		rest := c.waiters[:0]
+		for _, w := range c.waiters {
+			if !w.at.After(c.now) {
+				w.ch <- c.now
+				continue
+			}
+			rest = append(rest, w)
+		}
+		c.waiters = rest
+		c.mu.Unlock()
+	}
+	// The loop asks for the next tick only after it's done pairing.
+	c.waitForLoop(t)
+}
+
+func (c *fakeClock) waitForLoop(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		waiting := len(c.waiters) > 0
+		c.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("service loop is not waiting for the clock")
+}
+
+var (
+	blitz   = matchmaking.Pool{TimeControl: board.TimeControl{Base: 5 * time.Minute}}
+	rapid   = matchmaking.Pool{TimeControl: board.TimeControl{Base: 15 * time.Minute}}
+	options = matchmaking.Options{
+		Interval:       time.Second,
+		InitialGap:     50,
+		GapPerSecond:   10,
+		MaxGap:         300,
+		ProvisionalGap: 200,
+	}
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	matches []matchmaking.Match
+}
+
+func (r *recorder) onMatch(m matchmaking.Match) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.matches = append(r.matches, m)
+}
+
+func (r *recorder) get() []matchmaking.Match {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]matchmaking.Match(nil), r.matches...)
+}
+
+func start(t *testing.T) (*matchmaking.Service, *fakeClock, *recorder) {
+	clock := newFakeClock()
+	rec := &recorder{}
+	service := matchmaking.New(clock, options, rec.onMatch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return service, clock, rec
+}
+
+func join(t *testing.T, service *matchmaking.Service, id, rating int, pool matchmaking.Pool) {
+	t.Helper()
+	if err := service.Join(matchmaking.Ticket{UserID: id, Rating: rating, Pool: pool}); err != nil {
+		t.Fatalf("join %d: %v", id, err)
+	}
+}
+
+func pairs(matches []matchmaking.Match) [][2]int {
+	out := make([][2]int, len(matches))
+	for i, m := range matches {
+		out[i] = [2]int{m.White.UserID, m.Black.UserID}
+	}
+	return out
+}
+
+func TestClosePlayersPairImmediately(t *testing.T) {
+	service, _, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	join(t, service, 2, 1530, blitz)
+
+	got := pairs(rec.get())
+	if len(got) != 1 || got[0] != [2]int{1, 2} {
+		t.Fatalf("want 1 vs 2, got %v", got)
+	}
+	if _, ok := service.Waiting(1); ok {
+		t.Fatal("matched player is still waiting")
+	}
+}
+
+func TestDifferentPoolsNeverPair(t *testing.T) {
+	service, clock, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	join(t, service, 2, 1500, rapid)
+	clock.Advance(t, time.Minute, time.Second)
+
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("players of different pools paired: %v", pairs(got))
+	}
+}
+
+func TestGapWidensWithWaiting(t *testing.T) {
+	service, clock, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	join(t, service, 2, 1650, blitz)
+
+	// 150 points apart: both need 10 seconds of waiting.
+	clock.Advance(t, 9*time.Second, time.Second)
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("paired too early: %v", pairs(got))
+	}
+	clock.Advance(t, time.Second, time.Second)
+	if got := pairs(rec.get()); len(got) != 1 || got[0] != [2]int{1, 2} {
+		t.Fatalf("want 1 vs 2 after 10s, got %v", got)
+	}
+}
+
+func TestGapIsCapped(t *testing.T) {
+	service, clock, rec := start(t)
+	join(t, service, 1, 1000, blitz)
+	join(t, service, 2, 2000, blitz)
+	clock.Advance(t, 5*time.Minute, 10*time.Second)
+
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("gap above MaxGap accepted: %v", pairs(got))
+	}
+}
+
+func TestLongestWaitingIsServedFirst(t *testing.T) {
+	service, clock, rec := start(t)
+	// 1 and 2 are too far apart to ever meet.
+	join(t, service, 1, 1500, blitz)
+	clock.Advance(t, time.Second, time.Second)
+	join(t, service, 2, 1850, blitz)
+	clock.Advance(t, 30*time.Second, time.Second)
+
+	// 3 is closer to 2, but once 3 accepts both of them 1 has waited
+	// longer and gets the game.
+	join(t, service, 3, 1690, blitz)
+	clock.Advance(t, 10*time.Second, 10*time.Second)
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("paired too early: %v", pairs(got))
+	}
+	clock.Advance(t, 10*time.Second, 10*time.Second)
+	got := pairs(rec.get())
+	if len(got) != 1 || got[0] != [2]int{1, 3} {
+		t.Fatalf("want 1 vs 3, got %v", got)
+	}
+	if _, ok := service.Waiting(2); !ok {
+		t.Fatal("player 2 left the queue")
+	}
+}
+
+func TestClosestOpponentIsChosen(t *testing.T) {
+	service, clock, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	clock.Advance(t, 30*time.Second, time.Second)
+	join(t, service, 2, 1640, blitz)
+	join(t, service, 3, 1560, blitz)
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("newcomers paired outside of their gap: %v", pairs(got))
+	}
+
+	// After one long tick 1 accepts both and picks the closer one.
+	clock.Advance(t, 10*time.Second, 10*time.Second)
+	got := pairs(rec.get())
+	if len(got) != 1 || got[0] != [2]int{1, 3} {
+		t.Fatalf("want 1 vs 3, got %v", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	service, clock, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	if !service.Cancel(1) {
+		t.Fatal("cancel of waiting player failed")
+	}
+	if service.Cancel(1) {
+		t.Fatal("second cancel succeeded")
+	}
+	join(t, service, 2, 1500, blitz)
+	clock.Advance(t, 10*time.Second, time.Second)
+
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("cancelled player paired: %v", pairs(got))
+	}
+	if _, ok := service.Waiting(2); !ok {
+		t.Fatal("player 2 should still wait")
+	}
+}
+
+func TestJoinTwice(t *testing.T) {
+	service, _, _ := start(t)
+	join(t, service, 1, 1500, blitz)
+	err := service.Join(matchmaking.Ticket{UserID: 1, Rating: 1500, Pool: rapid})
+	if err != matchmaking.ErrAlreadyQueued {
+		t.Fatalf("want ErrAlreadyQueued, got %v", err)
+	}
+}
+
+func TestProvisionalStartsWithWiderGap(t *testing.T) {
+	service, _, rec := start(t)
+	join(t, service, 1, 1500, blitz)
+	newcomer := matchmaking.Ticket{UserID: 2, Rating: 1650, Provisional: true, Pool: blitz}
+	if err := service.Join(newcomer); err != nil {
+		t.Fatal(err)
+	}
+	// Established player still accepts only 50 points.
+	if got := rec.get(); len(got) != 0 {
+		t.Fatalf("established player took a far opponent: %v", pairs(got))
+	}
+
+	both := matchmaking.Ticket{UserID: 3, Rating: 1800, Provisional: true, Pool: blitz}
+	if err := service.Join(both); err != nil {
+		t.Fatal(err)
+	}
+	got := pairs(rec.get())
+	if len(got) != 1 || got[0] != [2]int{2, 3} {
+		t.Fatalf("want provisional 2 vs 3, got %v", got)
+	}
+}
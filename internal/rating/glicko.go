@@ -0,0 +1,176 @@
+// Package rating implements Glicko-2 ratings, one per time control
+// category. See http://www.glicko.net/glicko/glicko2.pdf for the math.
+package rating
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"ust_chess/internal/board"
+)
+
+const (
+	DefaultRating     = 1500
+	DefaultDeviation  = 350
+	DefaultVolatility = 0.06
+	// Players with bigger deviation are provisional: their rating is a
+	// guess so far.
+	ProvisionalDeviation = 110
+	MinDeviation         = 45
+
+	scale   = 173.7178
+	tau     = 0.5
+	epsilon = 0.000001
+)
+
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+func Default() Rating {
+	return Rating{DefaultRating, DefaultDeviation, DefaultVolatility}
+}
+
+func (r Rating) IsProvisional() bool {
+	return r.Deviation > ProvisionalDeviation
+}
+
+// String shows rounded rating with "?" for provisional ones.
+func (r Rating) String() string {
+	s := fmt.Sprintf("%.0f", r.Rating)
+	if r.IsProvisional() {
+		s += "?"
+	}
+	return s
+}
+
+// Result of a single game from the player's point of view.
+type Result struct {
+	Opponent Rating
+	Score    float64 // 1 win, 0.5 draw, 0 loss
+}
+
+// Update returns the rating after a rating period with the given games.
+// Without games only the deviation grows.
+func Update(player Rating, results []Result) Rating {
+	mu := (player.Rating - DefaultRating) / scale
+	phi := player.Deviation / scale
+	sigma := player.Volatility
+	if len(results) == 0 {
+		return clamp(Rating{player.Rating, math.Sqrt(phi*phi+sigma*sigma) * scale, sigma})
+	}
+
+	var vInv, deltaSum float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - DefaultRating) / scale
+		phiJ := result.Opponent.Deviation / scale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		deltaSum += g * (result.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * deltaSum
+
+	sigma = volatility(sigma, phi, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * deltaSum
+	return clamp(Rating{mu*scale + DefaultRating, phi * scale, sigma})
+}
+
+// volatility finds the new volatility with the Illinois algorithm (step 5
+// of the paper).
+func volatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+func clamp(r Rating) Rating {
+	r.Deviation = min(max(r.Deviation, MinDeviation), DefaultDeviation)
+	return r
+}
+
+// Category of time control. Every category has its own rating.
+type Category string
+
+const (
+	BULLET         Category = "bullet"
+	BLITZ          Category = "blitz"
+	RAPID          Category = "rapid"
+	CORRESPONDENCE Category = "correspondence"
+)
+
+var Categories = []Category{BULLET, BLITZ, RAPID, CORRESPONDENCE}
+
+// CategoryOf estimates game duration as base time plus 40 increments.
+// Games without clock are correspondence ones.
+func CategoryOf(tc board.TimeControl) Category {
+	if tc.IsCorrespondence() || !tc.IsTimed() {
+		return CORRESPONDENCE
+	}
+	estimate := tc.Base + 40*tc.Increment
+	switch {
+	case estimate < 3*time.Minute:
+		return BULLET
+	case estimate < 8*time.Minute:
+		return BLITZ
+	default:
+		return RAPID
+	}
+}
+
+// Point of rating history.
+type Point struct {
+	At        time.Time
+	Rating    float64
+	Deviation float64
+}
+
+// Player keeps the rating of one category and how it changed.
+type Player struct {
+	Rating
+	Games   int
+	History []Point
+}
+
+func NewPlayer() Player {
+	return Player{Rating: Default()}
+}
+
+// Play applies one game result to the player.
+func (p *Player) Play(opponent Rating, score float64, at time.Time) {
+	p.Rating = Update(p.Rating, []Result{{opponent, score}})
+	p.Games++
+	p.History = append(p.History, Point{at, p.Rating.Rating, p.Rating.Deviation})
+}
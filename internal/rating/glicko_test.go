@@ -0,0 +1,54 @@
+package rating_test
+
+import (
+	"math"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/rating"
+)
+
+// Example from the Glicko-2 paper.
+func TestUpdatePaperExample(t *testing.T) {
+	player := rating.Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := rating.Update(player, []rating.Result{
+		{Opponent: rating.Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: rating.Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: rating.Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	want := rating.Rating{Rating: 1464.06, Deviation: 151.52, Volatility: 0.05999}
+	if math.Abs(got.Rating-want.Rating) > 0.01 ||
+		math.Abs(got.Deviation-want.Deviation) > 0.01 ||
+		math.Abs(got.Volatility-want.Volatility) > 0.00001 {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestUpdateWithoutGames(t *testing.T) {
+	player := rating.Rating{Rating: 1500, Deviation: 50, Volatility: 0.06}
+	got := rating.Update(player, nil)
+	if got.Rating != 1500 || got.Deviation <= 50 {
+		t.Fatalf("only deviation should grow, got %+v", got)
+	}
+}
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		tc   board.TimeControl
+		want rating.Category
+	}{
+		{board.TimeControl{}, rating.CORRESPONDENCE},
+		{board.TimeControl{DaysPerMove: 3}, rating.CORRESPONDENCE},
+		{board.TimeControl{Base: time.Minute}, rating.BULLET},
+		{board.TimeControl{Base: 2 * time.Minute, Increment: time.Second}, rating.BULLET},
+		{board.TimeControl{Base: 3 * time.Minute, Increment: 2 * time.Second}, rating.BLITZ},
+		{board.TimeControl{Base: 5 * time.Minute, Increment: 3 * time.Second}, rating.BLITZ},
+		{board.TimeControl{Base: 10 * time.Minute}, rating.RAPID},
+		{board.TimeControl{Base: 15 * time.Minute, Increment: 10 * time.Second}, rating.RAPID},
+	}
+	for _, test := range tests {
+		if got := rating.CategoryOf(test.tc); got != test.want {
+			t.Errorf("%s: want %s, got %s", test.tc, test.want, got)
+		}
+	}
+}
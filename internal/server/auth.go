@@ -0,0 +1,399 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserExists    = errors.New("user already exists")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrBadName       = errors.New("name must be 1-32 characters")
+	ErrBadPassword   = errors.New("password must be at least 6 characters")
+	ErrBadSession    = errors.New("bad session")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrNotGuest      = errors.New("not a guest")
+	ErrCrossSite     = errors.New("request from another site")
+)
+
+const (
+	sessionCookie = "session"
+	sessionTTL    = 30 * 24 * time.Hour
+	userKey       = "user"
+)
+
+// Accounts keeps registered users in memory.
+type Accounts struct {
+	mu     sync.Mutex
+	users  map[int]*User
+	lastID int
+}
+
+func NewAccounts() *Accounts {
+	return &Accounts{users: make(map[int]*User)}
+}
+
+func (a *Accounts) Register(name, password string) (User, error) {
+	name, hash, err := checkCredentials(name, password)
+	if err != nil {
+		return User{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.findByName(name) != nil {
+		return User{}, errors.Join(ErrUserExists, errors.New(name))
+	}
+	key, err := sessionKey()
+	if err != nil {
+		return User{}, err
+	}
+	a.lastID++
+	user := &User{ID: a.lastID, Name: name, PasswordHash: hash, SessionKey: key}
+	a.users[user.ID] = user
+	return *user, nil
+}
+
+// sessionKey is a random value kept with the account and signed into its
+// cookies. A cookie of an account that is gone does not log into another
+// one that got the same id.
+func sessionKey() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func checkCredentials(name, password string) (string, []byte, error) {
+	name, err := checkName(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(password) < 6 {
+		return "", nil, ErrBadPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return name, hash, err
+}
+
+func checkName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > 32 {
+		return "", ErrBadName
+	}
+	return name, nil
+}
+
+// Add puts an existing user back, e.g. when loaded from storage. Users
+// stored without a session key get a new one, their old cookies no longer
+// count.
+func (a *Accounts) Add(user User) error {
+	if user.SessionKey == "" {
+		key, err := sessionKey()
+		if err != nil {
+			return err
+		}
+		user.SessionKey = key
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users[user.ID] = &user
+	a.lastID = max(a.lastID, user.ID)
+	return nil
+}
+
+func (a *Accounts) Login(name, password string) (User, error) {
+	a.mu.Lock()
+	user := a.findByName(strings.TrimSpace(name))
+	a.mu.Unlock()
+	if user == nil {
+		return User{}, errors.Join(ErrUserNotFound, errors.New(name))
+	}
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return User{}, ErrWrongPassword
+	}
+	return *user, nil
+}
+
+func (a *Accounts) Get(id int) (User, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	user, ok := a.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return *user, true
+}
+
+// IssueToken generates a new API token for the user. Only its hash is kept,
+// so the token is shown once and replaces the previous one.
+func (a *Accounts) IssueToken(id int) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	user, ok := a.users[id]
+	if !ok {
+		return "", errors.Join(ErrUserNotFound, fmt.Errorf("%d", id))
+	}
+	user.Token = hashToken(token)
+	return token, nil
+}
+
+func (a *Accounts) ByToken(token string) (User, bool) {
+	hash := hashToken(token)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, user := range a.users {
+		if user.Token != "" && hmac.Equal([]byte(user.Token), []byte(hash)) {
+			return *user, true
+		}
+	}
+	return User{}, false
+}
+
+func (a *Accounts) findByName(name string) *User {
+	for _, user := range a.users {
+		if !user.Guest && strings.EqualFold(user.Name, name) {
+			return user
+		}
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sessions signs and verifies cookie values. A value is the base64 encoded
+// fields, expiry unix time and hmac, all joined by dots.
+type Sessions struct {
+	secret []byte
+}
+
+func NewSessions(secret []byte) Sessions {
+	return Sessions{secret: secret}
+}
+
+func (s Sessions) Sign(expires time.Time, fields ...string) string {
+	parts := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		parts = append(parts, base64.RawURLEncoding.EncodeToString([]byte(field)))
+	}
+	payload := strings.Join(append(parts, strconv.FormatInt(expires.Unix(), 10)), ".")
+	return payload + "." + s.mac(payload)
+}
+
+func (s Sessions) Verify(value string) ([]string, error) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return nil, ErrBadSession
+	}
+	payload, mac := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(s.mac(payload))) {
+		return nil, ErrBadSession
+	}
+	parts := strings.Split(payload, ".")
+	exp, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return nil, ErrBadSession
+	}
+	fields := make([]string, 0, len(parts)-1)
+	for _, part := range parts[:len(parts)-1] {
+		field, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, ErrBadSession
+		}
+		fields = append(fields, string(field))
+	}
+	return fields, nil
+}
+
+func (s Sessions) mac(payload string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Authenticate resolves the user from a bearer token, the session cookie or
+// the guest cookie.
+// Anonymous requests pass through untouched.
+func (s *Server) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if user, ok := s.userFromRequest(c); ok {
+			c.Set(userKey, user)
+		}
+		return next(c)
+	}
+}
+
+// SameOrigin rejects requests that change something when a browser sent
+// them from another site: the cookies come along with them all the same.
+// Requests with a bearer token carry no cookies and pass.
+func (s *Server) SameOrigin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		switch {
+		case req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions:
+		case strings.HasPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer "):
+		case !sameOrigin(req):
+			return echo.NewHTTPError(http.StatusForbidden, ErrCrossSite.Error())
+		}
+		return next(c)
+	}
+}
+
+// sameOrigin tells by the headers browsers send where the request comes
+// from. Without any of them it is no browser.
+func sameOrigin(req *http.Request) bool {
+	if site := req.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	origin := req.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		origin = req.Referer()
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+// RequireUser rejects anonymous requests: pages redirect to the login form,
+// API calls get 401.
+func (s *Server) RequireUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := currentUser(c); ok {
+			return next(c)
+		}
+		if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+			return c.Redirect(http.StatusSeeOther, "/login")
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, ErrUnauthorized.Error())
+	}
+}
+
+func (s *Server) userFromRequest(c echo.Context) (User, bool) {
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return s.accounts.ByToken(token)
+	}
+	if user, ok := s.sessionUser(c); ok {
+		return user, true
+	}
+	return s.guestFromRequest(c)
+}
+
+// sessionUser is the user of the session cookie: its fields are the id
+// and the session key of the account.
+func (s *Server) sessionUser(c echo.Context) (User, bool) {
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil {
+		return User{}, false
+	}
+	fields, err := s.sessions.Verify(cookie.Value)
+	if err != nil || len(fields) != 2 {
+		return User{}, false
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return User{}, false
+	}
+	user, ok := s.accounts.Get(id)
+	if !ok || !hmac.Equal([]byte(user.SessionKey), []byte(fields[1])) {
+		return User{}, false
+	}
+	return user, true
+}
+
+func currentUser(c echo.Context) (User, bool) {
+	user, ok := c.Get(userKey).(User)
+	return user, ok
+}
+
+func (s *Server) setSession(c echo.Context, user User) {
+	expires := time.Now().Add(sessionTTL)
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.sessions.Sign(expires, strconv.Itoa(user.ID), user.SessionKey),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+type AuthOutDto struct {
+	Register bool
+	Convert  bool // guest becomes a registered user
+	Error    string
+}
+
+func (s *Server) LoginPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "login.html", AuthOutDto{})
+}
+
+func (s *Server) RegisterPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "login.html", AuthOutDto{Register: true})
+}
+
+func (s *Server) Login(c echo.Context) error {
+	user, err := s.accounts.Login(c.FormValue("name"), c.FormValue("password"))
+	if err != nil {
+		return c.Render(http.StatusUnauthorized, "login.html", AuthOutDto{Error: err.Error()})
+	}
+	s.setSession(c, user)
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+func (s *Server) Register(c echo.Context) error {
+	user, err := s.accounts.Register(c.FormValue("name"), c.FormValue("password"))
+	if err != nil {
+		return c.Render(http.StatusBadRequest, "login.html", AuthOutDto{Register: true, Error: err.Error()})
+	}
+	s.saveUser(user.ID)
+	s.setSession(c, user)
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+func (s *Server) Logout(c echo.Context) error {
+	c.SetCookie(&http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	c.SetCookie(&http.Cookie{Name: guestCookie, Path: "/", MaxAge: -1})
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// Token issues an API token for scripted clients.
+func (s *Server) Token(c echo.Context) error {
+	user, _ := currentUser(c)
+	if user.Guest {
+		return echo.NewHTTPError(http.StatusForbidden, "guests can't have API tokens")
+	}
+	token, err := s.accounts.IssueToken(user.ID)
+	if err != nil {
+		return err
+	}
+	s.saveUser(user.ID)
+	return c.JSON(http.StatusOK, map[string]string{"token": token})
+}
@@ -0,0 +1,199 @@
+package server_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"ust_chess/internal/server"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSessions(t *testing.T) {
+	sessions := server.NewSessions([]byte("secret"))
+	valid := sessions.Sign(time.Now().Add(time.Hour), "1", "alice")
+	payload := valid[:strings.LastIndexByte(valid, '.')]
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"valid", valid, []string{"1", "alice"}},
+		{"no fields", sessions.Sign(time.Now().Add(time.Hour)), []string{}},
+		{"bad mac", payload + ".AAAA", nil},
+		{"no mac", payload, nil},
+		{"other secret", server.NewSessions([]byte("other")).Sign(time.Now().Add(time.Hour), "1", "alice"), nil},
+		{"tampered field", base64.RawURLEncoding.EncodeToString([]byte("2")) + valid[strings.IndexByte(valid, '.'):], nil},
+		{"expired", sessions.Sign(time.Now().Add(-time.Second), "1"), nil},
+		{"signed by hand", signed("MQ.4102444800"), []string{"1"}},
+		{"bad base64", signed("!!!.4102444800"), nil},
+		{"bad expiry", signed("MQ.soon"), nil},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := sessions.Verify(tt.value)
+			if tt.want == nil {
+				if !errors.Is(err, server.ErrBadSession) {
+					t.Fatalf("got %q, %v, want %v", fields, err, server.ErrBadSession)
+				}
+				return
+			}
+			if err != nil || strings.Join(fields, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %q, %v, want %q", fields, err, tt.want)
+			}
+		})
+	}
+}
+
+// signed puts the mac of the secret on the payload the way Sessions do,
+// for payloads Sign doesn't make.
+func signed(payload string) string {
+	h := hmac.New(sha256.New, []byte("secret"))
+	h.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestAccounts(t *testing.T) {
+	accounts := server.NewAccounts()
+	alice, err := accounts.Register(" alice ", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Name != "alice" || string(alice.PasswordHash) == "password" || alice.SessionKey == "" {
+		t.Fatalf("registered %+v", alice)
+	}
+	if _, err := accounts.Register("Alice", "password"); !errors.Is(err, server.ErrUserExists) {
+		t.Fatalf("same name: %v", err)
+	}
+	if _, err := accounts.Register("bob", "short"); !errors.Is(err, server.ErrBadPassword) {
+		t.Fatalf("short password: %v", err)
+	}
+	if _, err := accounts.Register(" ", "password"); !errors.Is(err, server.ErrBadName) {
+		t.Fatalf("empty name: %v", err)
+	}
+
+	if user, err := accounts.Login("ALICE", "password"); err != nil || user.ID != alice.ID {
+		t.Fatalf("login: %+v, %v", user, err)
+	}
+	if _, err := accounts.Login("alice", "wrong password"); !errors.Is(err, server.ErrWrongPassword) {
+		t.Fatalf("wrong password: %v", err)
+	}
+	if _, err := accounts.Login("bob", "password"); !errors.Is(err, server.ErrUserNotFound) {
+		t.Fatalf("unknown user: %v", err)
+	}
+}
+
+// client is a browser for the routes of a server, with its cookies.
+type client struct {
+	t       *testing.T
+	e       *echo.Echo
+	cookies map[string]*http.Cookie
+}
+
+func newClient(t *testing.T, srv *server.Server) *client {
+	e := echo.New()
+	e.Renderer = pageName{}
+	srv.Routes(e)
+	return &client{t: t, e: e, cookies: map[string]*http.Cookie{}}
+}
+
+// pageName renders only the name of the template.
+type pageName struct{}
+
+func (pageName) Render(w io.Writer, name string, data any, c echo.Context) error {
+	_, err := io.WriteString(w, name)
+	return err
+}
+
+// post sends the form as a browser on the same site would and keeps the
+// cookies it gets back.
+func (c *client) post(path string, form url.Values, headers ...string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	return c.do(req)
+}
+
+func (c *client) get(path string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	return c.do(httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+func (c *client) do(req *http.Request) *httptest.ResponseRecorder {
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	c.e.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie
+		}
+	}
+	return rec
+}
+
+func (c *client) register(name string) {
+	c.t.Helper()
+	rec := c.post("/register", url.Values{"name": {name}, "password": {"password"}})
+	if rec.Code != http.StatusSeeOther {
+		c.t.Fatalf("register %s: %d %s", name, rec.Code, rec.Body)
+	}
+}
+
+// TestSessionOfGoneAccount: ids are given anew when the accounts are lost
+// with the process, a cookie of the old account must not log into the new
+// one with the same id.
+func TestSessionOfGoneAccount(t *testing.T) {
+	secret := []byte("kept secret")
+	before := newClient(t, server.New(secret, server.NewMemoryStorage()))
+	before.register("alice")
+	if rec := before.post("/token", nil); rec.Code != http.StatusOK {
+		t.Fatalf("own session: %d %s", rec.Code, rec.Body)
+	}
+
+	after := newClient(t, server.New(secret, server.NewMemoryStorage()))
+	after.register("mallory")
+	stale := &client{t: t, e: after.e, cookies: before.cookies}
+	if rec := stale.post("/token", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("old cookie on the new account: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	c := newClient(t, server.New([]byte("secret"), server.NewMemoryStorage()))
+	c.register("alice")
+	tests := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"same site", nil, http.StatusOK},
+		{"cross site", []string{"Sec-Fetch-Site", "cross-site"}, http.StatusForbidden},
+		{"other origin", []string{"Sec-Fetch-Site", "", "Origin", "https://evil.example"}, http.StatusForbidden},
+		{"own origin", []string{"Sec-Fetch-Site", "", "Origin", "http://example.com"}, http.StatusOK},
+		{"other referer", []string{"Sec-Fetch-Site", "", "Referer", "https://evil.example/page"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := c.post("/token", nil, tt.headers...); rec.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+}
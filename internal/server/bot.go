@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/engine"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// botClockShare: the bot spends this part of its time left on a move.
+	botClockShare = 30
+	minBotTime    = 50 * time.Millisecond
+)
+
+var (
+	ErrNoSuchLevel = errors.New("no such computer level")
+	ErrRatedBot    = errors.New("computer can't play rated games")
+)
+
+// Bot is the computer player of the level. Bots have negative ids, so
+// rooms keep them as any other player.
+func Bot(level int) User {
+	return User{ID: -level, Name: fmt.Sprintf("Computer, level %d", level)}
+}
+
+func (u User) IsBot() bool {
+	return u.ID < 0
+}
+
+func botLevels() []int {
+	levels := make([]int, len(engine.Levels))
+	for i := range levels {
+		levels[i] = i + 1
+	}
+	return levels
+}
+
+// SeatBot seats the computer of the level, from 1 to len(engine.Levels),
+// on the empty seat of a casual game.
+func (r *Room) SeatBot(user User, white bool, level int) error {
+	if level < 1 || level > len(engine.Levels) {
+		return ErrNoSuchLevel
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.Owner != user.ID:
+		return ErrNotOwner
+	case r.Rated:
+		return ErrRatedBot
+	case len(r.Game.History) > 0 && !r.Game.IsEnded():
+		return ErrGameStarted
+	}
+	return r.seat(Bot(level), white)
+}
+
+// botToMove returns the search options when a bot is to move in a game
+// with both seats taken. The time is cut to fit the clock of the bot.
+// Caller holds the lock.
+func (r *Room) botToMove(now time.Time) (engine.Options, bool) {
+	game := &r.Game
+	bot := r.White
+	if game.IsBlackTurn {
+		bot = r.Black
+	}
+	if !bot.IsBot() || r.White.ID == 0 || r.Black.ID == 0 ||
+		game.IsEnded() || game.IsPause || game.IsAdjourned || game.IsSuspended {
+		return engine.Options{}, false
+	}
+	options := engine.Level(-bot.ID)
+	if game.Clock.IsTimed() {
+		budget := max(game.Clock.Remaining(now, game.IsBlackTurn)/botClockShare, minBotTime)
+		if options.Time == 0 || budget < options.Time {
+			options.Time = budget
+		}
+	}
+	return options, true
+}
+
+// playBot lets the bot to move think in the background and plays its move
+// unless the game went on meanwhile.
+func (s *Server) playBot(room *Room) {
+	room.mu.Lock()
+	options, ok := room.botToMove(room.now())
+	if !ok || room.thinking {
+		room.mu.Unlock()
+		return
+	}
+	room.thinking = true
+	pos, plies := room.Game.Board.Clone(), len(room.Game.History)
+	room.mu.Unlock()
+
+	go func() {
+		result, err := engine.New(options).Search(context.Background(), &pos)
+		room.mu.Lock()
+		room.thinking = false
+		_, toMove := room.botToMove(room.now())
+		moved := err == nil && toMove && len(room.Game.History) == plies
+		if moved {
+			err = room.apply(board.Event{Type: board.MOVED, At: room.now(), Move: &result.Move})
+		}
+		room.mu.Unlock()
+		if err != nil {
+			log.Error().Err(err).Int("room", room.ID).Msg("bot move")
+			return
+		}
+		if moved {
+			s.publish(room)
+			s.settle(room)
+			s.playBot(room)
+		}
+	}()
+}
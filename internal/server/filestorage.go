@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// FileStorage keeps data as JSON files in a directory:
+//
+//	users/<id>.json
+//	rooms/<id>.json
+//	events.wal   events of all games, see WAL
+//	games.jsonl  one finished game per line
+type FileStorage struct {
+	mu      sync.Mutex
+	dir     string
+	wal     *WAL
+	records [][]byte // read from the log on open, until Events
+	walErr  error    // damage found on open
+}
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	for _, sub := range []string{"users", "rooms"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	wal, records, err := OpenWAL(filepath.Join(dir, "events.wal"), func(record []byte) error {
+		var event GameEvent
+		return json.Unmarshal(record, &event)
+	})
+	if err != nil && !errors.Is(err, ErrCorruptLog) {
+		return nil, err
+	}
+	return &FileStorage{dir: dir, wal: wal, records: records, walErr: err}, nil
+}
+
+func (f *FileStorage) SaveUser(user UserRecord) error {
+	return f.writeJSON(filepath.Join("users", strconv.Itoa(user.ID)+".json"), user)
+}
+
+func (f *FileStorage) Users() ([]UserRecord, error) {
+	return readDir[UserRecord](f, "users")
+}
+
+func (f *FileStorage) SaveRoom(room RoomRecord) error {
+	return f.writeJSON(filepath.Join("rooms", strconv.Itoa(room.ID)+".json"), room)
+}
+
+func (f *FileStorage) Rooms() ([]RoomRecord, error) {
+	return readDir[RoomRecord](f, "rooms")
+}
+
+func (f *FileStorage) SaveGame(game GameRecord) error {
+	return f.appendJSON("games.jsonl", game)
+}
+
+func (f *FileStorage) Games(userID int) ([]GameRecord, error) {
+	all, err := readLines[GameRecord](f, "games.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	var games []GameRecord
+	for _, game := range all {
+		if game.White == userID || game.Black == userID {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (f *FileStorage) AppendEvent(event GameEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return f.wal.Append(data)
+}
+
+// Events returns events read when the storage was opened, so it is meant
+// to be called once on start.
+func (f *FileStorage) Events() ([]GameEvent, error) {
+	f.mu.Lock()
+	records, err := f.records, f.walErr
+	f.records, f.walErr = nil, nil
+	f.mu.Unlock()
+
+	events := make([]GameEvent, 0, len(records))
+	for i, record := range records {
+		var event GameEvent
+		if err := json.Unmarshal(record, &event); err != nil {
+			return events, errors.Join(ErrCorruptLog, fmt.Errorf("event %d", i+1), err)
+		}
+		events = append(events, event)
+	}
+	return events, err
+}
+
+func (f *FileStorage) Close() error {
+	return f.wal.Close()
+}
+
+// writeJSON replaces the file atomically: a crash leaves either the old or
+// the new version.
+func (f *FileStorage) writeJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := filepath.Join(f.dir, name)
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileStorage) appendJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(filepath.Join(f.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readDir[T any](f *FileStorage, sub string) ([]T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := os.ReadDir(filepath.Join(f.dir, sub))
+	if err != nil {
+		return nil, err
+	}
+	var items []T
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.dir, sub, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, errors.Join(fmt.Errorf("%s/%s", sub, entry.Name()), err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readLines[T any](f *FileStorage, name string) ([]T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(filepath.Join(f.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var items []T
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var item T
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return nil, errors.Join(fmt.Errorf("%s:%d", name, line), err)
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// finishedShown is how many finished games the dashboard lists.
+const finishedShown = 20
+
+// MyGameDto is a game of the user on the dashboard.
+type MyGameDto struct {
+	ID          int // room
+	Opponent    string
+	Color       string
+	TimeControl string
+	Moves       int
+	MyTurn      bool
+	Deadline    *time.Time // of the side to move in correspondence games
+	Result      string
+	Termination string
+	Ended       time.Time
+}
+
+type MyGamesOutDto struct {
+	User     *User
+	Playing  []MyGameDto // games waiting for the user's move come first
+	Finished []MyGameDto // newest first
+}
+
+func (s *Server) myGames(user User) MyGamesOutDto {
+	out := MyGamesOutDto{User: &user, Playing: []MyGameDto{}, Finished: []MyGameDto{}}
+	now := s.now()
+	for _, room := range s.Rooms() {
+		s.settle(room)
+		room.mu.Lock()
+		color := room.Color(user)
+		if color == "" || room.Game.IsEnded() {
+			room.mu.Unlock()
+			continue
+		}
+		opponent := room.Black.Name
+		if color == "black" {
+			opponent = room.White.Name
+		}
+		game := MyGameDto{
+			ID:          room.ID,
+			Opponent:    opponent,
+			Color:       color,
+			TimeControl: room.Game.Clock.TimeControl.String(),
+			Moves:       len(room.Game.History),
+			MyTurn:      color == sideToMove(room.Game.IsBlackTurn),
+		}
+		if deadline, ok := room.Game.Deadline(now); ok && room.Game.Clock.IsCorrespondence() {
+			game.Deadline = &deadline
+		}
+		room.mu.Unlock()
+		out.Playing = append(out.Playing, game)
+	}
+	slices.SortFunc(out.Playing, func(a, b MyGameDto) int {
+		switch {
+		case a.MyTurn != b.MyTurn && a.MyTurn:
+			return -1
+		case a.MyTurn != b.MyTurn:
+			return 1
+		case a.Deadline != nil && b.Deadline != nil:
+			return a.Deadline.Compare(*b.Deadline)
+		case a.Deadline != nil:
+			return -1
+		case b.Deadline != nil:
+			return 1
+		}
+		return a.ID - b.ID
+	})
+
+	records, err := s.storage.Games(user.ID)
+	if err != nil {
+		log.Error().Err(err).Int("user", user.ID).Msg("games not loaded")
+	}
+	slices.SortFunc(records, func(a, b GameRecord) int { return b.Ended.Compare(a.Ended) })
+	for _, record := range records[:min(len(records), finishedShown)] {
+		game := MyGameDto{
+			ID:          record.RoomID,
+			Opponent:    record.BlackName,
+			Color:       "white",
+			TimeControl: record.TimeControl.String(),
+			Moves:       len(record.Moves),
+			Result:      record.Result.String(),
+			Termination: record.Termination.String(),
+			Ended:       record.Ended,
+		}
+		if record.Black == user.ID {
+			game.Opponent, game.Color = record.WhiteName, "black"
+		}
+		out.Finished = append(out.Finished, game)
+	}
+	return out
+}
+
+// MyGames shows games the user plays and recently finished.
+func (s *Server) MyGames(c echo.Context) error {
+	user, _ := currentUser(c)
+	return c.Render(http.StatusOK, "games.html", s.myGames(user))
+}
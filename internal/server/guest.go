@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	guestCookie = "guest"
+	guestTTL    = 365 * 24 * time.Hour
+)
+
+// NewGuest creates a user without password. Guests are identified only by
+// the signed cookie, so the nickname doesn't have to be unique.
+func (a *Accounts) NewGuest(nickname string) (User, error) {
+	nickname, err := checkName(nickname)
+	if err != nil {
+		return User{}, err
+	}
+	key, err := sessionKey()
+	if err != nil {
+		return User{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastID++
+	user := &User{ID: a.lastID, Name: nickname, Guest: true, SessionKey: key}
+	a.users[user.ID] = user
+	return *user, nil
+}
+
+// RestoreGuest brings back a guest known from a cookie, e.g. after the
+// server lost its memory. Taken ids belong to someone else unless the
+// session key is theirs.
+func (a *Accounts) RestoreGuest(id int, nickname, key string) (User, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if user, ok := a.users[id]; ok {
+		return *user, user.Guest && hmac.Equal([]byte(user.SessionKey), []byte(key))
+	}
+	user := &User{ID: id, Name: nickname, Guest: true, SessionKey: key}
+	a.users[id] = user
+	a.lastID = max(a.lastID, id)
+	return *user, true
+}
+
+func (a *Accounts) Rename(id int, nickname string) (User, error) {
+	nickname, err := checkName(nickname)
+	if err != nil {
+		return User{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	user, ok := a.users[id]
+	if !ok {
+		return User{}, errors.Join(ErrUserNotFound, fmt.Errorf("%d", id))
+	}
+	if !user.Guest {
+		return User{}, ErrNotGuest
+	}
+	user.Name = nickname
+	return *user, nil
+}
+
+// Convert turns a guest into a registered account. The id stays the same,
+// so rooms and games of the guest remain theirs.
+func (a *Accounts) Convert(id int, name, password string) (User, error) {
+	name, hash, err := checkCredentials(name, password)
+	if err != nil {
+		return User{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	user, ok := a.users[id]
+	if !ok {
+		return User{}, errors.Join(ErrUserNotFound, fmt.Errorf("%d", id))
+	}
+	if !user.Guest {
+		return User{}, ErrNotGuest
+	}
+	if a.findByName(name) != nil {
+		return User{}, errors.Join(ErrUserExists, errors.New(name))
+	}
+	user.Name = name
+	user.PasswordHash = hash
+	user.Guest = false
+	return *user, nil
+}
+
+func (s *Server) guestFromRequest(c echo.Context) (User, bool) {
+	cookie, err := c.Cookie(guestCookie)
+	if err != nil {
+		return User{}, false
+	}
+	fields, err := s.sessions.Verify(cookie.Value)
+	if err != nil || len(fields) != 3 || fields[2] == "" {
+		return User{}, false
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return User{}, false
+	}
+	return s.accounts.RestoreGuest(id, fields[1], fields[2])
+}
+
+func (s *Server) setGuest(c echo.Context, user User) {
+	expires := time.Now().Add(guestTTL)
+	c.SetCookie(&http.Cookie{
+		Name:     guestCookie,
+		Value:    s.sessions.Sign(expires, strconv.Itoa(user.ID), user.Name, user.SessionKey),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Guest starts playing as a guest or changes the nickname of the current one.
+func (s *Server) Guest(c echo.Context) error {
+	var (
+		user User
+		err  error
+	)
+	if current, ok := currentUser(c); ok {
+		user, err = s.accounts.Rename(current.ID, c.FormValue("nickname"))
+	} else {
+		user, err = s.accounts.NewGuest(c.FormValue("nickname"))
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	s.updateSeats(user)
+	s.saveUser(user.ID)
+	s.setGuest(c, user)
+	return c.Redirect(http.StatusSeeOther, redirectBack(c))
+}
+
+func (s *Server) ConvertPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "login.html", AuthOutDto{Register: true, Convert: true})
+}
+
+func (s *Server) ConvertGuest(c echo.Context) error {
+	current, _ := currentUser(c)
+	user, err := s.accounts.Convert(current.ID, c.FormValue("name"), c.FormValue("password"))
+	if err != nil {
+		return c.Render(http.StatusBadRequest, "login.html",
+			AuthOutDto{Register: true, Convert: true, Error: err.Error()})
+	}
+	s.updateSeats(user)
+	s.saveUser(user.ID)
+	s.setSession(c, user)
+	c.SetCookie(&http.Cookie{Name: guestCookie, Path: "/", MaxAge: -1})
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// updateSeats refreshes copies of the user kept by rooms.
+func (s *Server) updateSeats(user User) {
+	for _, room := range s.Rooms() {
+		room.mu.Lock()
+		for _, seat := range []*User{&room.White, &room.Black} {
+			if seat.ID == user.ID {
+				*seat = user
+			}
+		}
+		room.mu.Unlock()
+	}
+}
+
+func redirectBack(c echo.Context) string {
+	if next := c.FormValue("next"); len(next) > 1 && next[0] == '/' && next[1] != '/' {
+		return next
+	}
+	return "/"
+}
@@ -0,0 +1,138 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"ust_chess/internal/rating"
+	"ust_chess/internal/server"
+)
+
+// foolsMate plays 1. f3 e5 2. g4 Qh4# in the room, black wins.
+func foolsMate(t *testing.T, room int, white, black *client) {
+	t.Helper()
+	moves := []struct {
+		player         *client
+		ix, iy, fx, fy int
+	}{
+		{white, 2, 1, 2, 2},
+		{black, 3, 6, 3, 4},
+		{white, 1, 1, 1, 3},
+		{black, 4, 7, 0, 3},
+	}
+	for _, m := range moves {
+		path := fmt.Sprintf("/room/%d/move?ix=%d&iy=%d&fx=%d&fy=%d", room, m.ix, m.iy, m.fx, m.fy)
+		if rec := m.player.post(path, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+// ratedGame creates a rated blitz room of the white player, seats the
+// black one and returns the id of the room.
+func ratedGame(t *testing.T, white, black *client) int {
+	t.Helper()
+	rec := white.post("/room", url.Values{"minutes": {"5"}, "rated": {"on"}})
+	var id int
+	if _, err := fmt.Sscanf(rec.Header().Get("Location"), "/room/%d", &id); err != nil {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if rec := black.post(fmt.Sprintf("/room/%d/join", id), url.Values{"color": {"black"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("join: %d %s", rec.Code, rec.Body)
+	}
+	return id
+}
+
+func blitz(t *testing.T, c *client, user int) rating.Player {
+	t.Helper()
+	rec := c.get(fmt.Sprintf("/api/users/%d/ratings", user))
+	var ratings map[rating.Category]rating.Player
+	if err := json.Unmarshal(rec.Body.Bytes(), &ratings); err != nil {
+		t.Fatalf("ratings: %d %s", rec.Code, rec.Body)
+	}
+	return ratings[rating.BLITZ]
+}
+
+// TestGuestConversion: games of a guest stay with the account it becomes,
+// and only games played as an account are rated.
+func TestGuestConversion(t *testing.T) {
+	srv := server.New([]byte("secret"), server.NewMemoryStorage())
+	guest, alice := newClient(t, srv), newClient(t, srv)
+	if rec := guest.post("/guest", url.Values{"nickname": {"ghost"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("guest: %d %s", rec.Code, rec.Body)
+	}
+	alice.register("alice")
+
+	first := ratedGame(t, guest, alice)
+	foolsMate(t, first, guest, alice)
+	room, err := srv.Room(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ghost, aliceID := room.White, room.Black.ID
+	if !ghost.Guest || !room.Game.IsEnded() {
+		t.Fatalf("white %+v, ended %v", ghost, room.Game.IsEnded())
+	}
+	if games := blitz(t, alice, aliceID).Games; games != 0 {
+		t.Fatalf("game with a guest rated: %d games", games)
+	}
+
+	rec := guest.post("/guest/convert", url.Values{"name": {"casper"}, "password": {"password"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("convert: %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := guest.cookies["guest"]; ok {
+		t.Fatal("guest cookie kept")
+	}
+	if rec := guest.post("/token", nil); rec.Code != http.StatusOK {
+		t.Fatalf("token of the account: %d %s", rec.Code, rec.Body)
+	}
+	if white := room.White; white.ID != ghost.ID || white.Guest || white.Name != "casper" {
+		t.Fatalf("seat after conversion %+v", white)
+	}
+
+	second := ratedGame(t, guest, alice)
+	foolsMate(t, second, guest, alice)
+	casper, winner := blitz(t, guest, ghost.ID), blitz(t, alice, aliceID)
+	if casper.Games != 1 || winner.Games != 1 {
+		t.Fatalf("games: %d and %d, want 1", casper.Games, winner.Games)
+	}
+	if casper.Rating.Rating >= rating.Default().Rating || winner.Rating.Rating <= rating.Default().Rating {
+		t.Fatalf("ratings: loser %.0f, winner %.0f", casper.Rating.Rating, winner.Rating.Rating)
+	}
+}
+
+func TestGuestCookie(t *testing.T) {
+	secret := []byte("secret")
+	srv := server.New(secret, server.NewMemoryStorage())
+	owner := newClient(t, srv)
+	owner.register("alice") // takes id 1
+
+	sessions := server.NewSessions(secret)
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		cookie string
+		want   int
+	}{
+		{"forged", server.NewSessions([]byte("guess")).Sign(later, "2", "ghost", "key"), http.StatusUnauthorized},
+		{"expired", sessions.Sign(time.Now().Add(-time.Minute), "2", "ghost", "key"), http.StatusUnauthorized},
+		{"without key", sessions.Sign(later, "2", "ghost"), http.StatusUnauthorized},
+		{"id of an account", sessions.Sign(later, "1", "ghost", "key"), http.StatusUnauthorized},
+		// A guest the server forgot comes back, and guests get no tokens.
+		{"restored", sessions.Sign(later, "5", "ghost", "key"), http.StatusForbidden},
+		{"restored, other key", sessions.Sign(later, "5", "ghost", "other"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(t, srv)
+			c.cookies["guest"] = &http.Cookie{Name: "guest", Value: tt.cookie}
+			if rec := c.post("/token", nil); rec.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+}
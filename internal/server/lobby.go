@@ -0,0 +1,241 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"ust_chess/internal/board"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrChallengeNotFound = errors.New("challenge not found")
+	ErrOwnChallenge      = errors.New("can't accept own challenge")
+)
+
+const lobbyTopic = "lobby"
+
+// Challenge is an open invitation to play. The first user to accept it
+// gets a room with the creator.
+type Challenge struct {
+	ID          int
+	Creator     User
+	TimeControl board.TimeControl
+	Color       string // "white", "black" or "" for random
+	Rated       bool
+	Created     time.Time
+}
+
+type RoomSummaryDto struct {
+	ID          int
+	TimeControl string
+	Mode        string
+	White       string
+	Black       string
+	Open        bool // has a free seat
+	Elapsed     string
+	Result      string
+}
+
+type ChallengeDto struct {
+	ID          int
+	Creator     string
+	CreatorID   int
+	TimeControl string
+	Mode        string
+	Color       string
+}
+
+type LobbyOutDto struct {
+	User       *User
+	Searching  string // time control the user waits for in matchmaking
+	Rooms      []RoomSummaryDto
+	Challenges []ChallengeDto
+	BotLevels  []int
+}
+
+// Summary describes the room for the lobby. Elapsed time runs from the
+// first move to the last one for finished games.
+func (r *Room) Summary(now time.Time) RoomSummaryDto {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := RoomSummaryDto{
+		ID:          r.ID,
+		TimeControl: r.Game.Clock.TimeControl.String(),
+		Mode:        mode(r.Variant, r.Rated),
+		White:       r.White.Name,
+		Black:       r.Black.Name,
+		Open:        r.White.ID == 0 || r.Black.ID == 0,
+	}
+	var elapsed time.Duration
+	if history := r.Game.History; len(history) > 0 {
+		end := now
+		if r.Game.IsEnded() {
+			end = r.Game.LastMoveTime
+		}
+		elapsed = end.Sub(history[0].At)
+	}
+	summary.Elapsed = fmt.Sprintf("%d:%02d", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+	if r.Game.IsEnded() {
+		summary.Result = r.Game.Result.String()
+	}
+	return summary
+}
+
+func mode(variant board.Variant, rated bool) string {
+	if rated {
+		return variant.String() + " rated"
+	}
+	return variant.String() + " casual"
+}
+
+func (s *Server) lobby(c echo.Context) LobbyOutDto {
+	out := LobbyOutDto{Rooms: []RoomSummaryDto{}, Challenges: []ChallengeDto{}, BotLevels: botLevels()}
+	if user, ok := currentUser(c); ok {
+		out.User = &user
+		if ticket, ok := s.matchmaker.Waiting(user.ID); ok {
+			out.Searching = ticket.Pool.TimeControl.String()
+		}
+	}
+	now := s.now()
+	for _, room := range s.Rooms() {
+		s.settle(room)
+		if summary := room.Summary(now); summary.Result == "" {
+			out.Rooms = append(out.Rooms, summary)
+		}
+	}
+	s.mu.Lock()
+	for _, challenge := range s.challenges {
+		out.Challenges = append(out.Challenges, ChallengeDto{
+			ID:          challenge.ID,
+			Creator:     challenge.Creator.Name,
+			CreatorID:   challenge.Creator.ID,
+			TimeControl: challenge.TimeControl.String(),
+			Mode:        mode(board.CLASSIC, challenge.Rated),
+			Color:       challenge.Color,
+		})
+	}
+	s.mu.Unlock()
+	sort.Slice(out.Challenges, func(i, j int) bool { return out.Challenges[i].ID < out.Challenges[j].ID })
+	return out
+}
+
+func (s *Server) Index(c echo.Context) error {
+	return c.Render(http.StatusOK, "lobby.html", s.lobby(c))
+}
+
+func (s *Server) LobbyJSON(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.lobby(c))
+}
+
+// LobbyEvents streams lobby changes. Logged in users also get notified
+// when their challenge is accepted.
+func (s *Server) LobbyEvents(c echo.Context) error {
+	topics := []string{lobbyTopic}
+	if user, ok := currentUser(c); ok {
+		topics = append(topics, userTopic(user.ID))
+	}
+	events, cancel := s.stream.Subscribe(topics...)
+	defer cancel()
+	return Stream(c, events)
+}
+
+func (s *Server) Challenge(c echo.Context) error {
+	user, _ := currentUser(c)
+	tc, err := timeControlParam(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	color := c.FormValue("color")
+	if color != "white" && color != "black" {
+		color = ""
+	}
+	s.mu.Lock()
+	s.lastChallengeID++
+	challenge := &Challenge{
+		ID:          s.lastChallengeID,
+		Creator:     user,
+		TimeControl: tc,
+		Color:       color,
+		Rated:       c.FormValue("rated") != "",
+		Created:     s.now(),
+	}
+	s.challenges[challenge.ID] = challenge
+	s.mu.Unlock()
+	s.publishLobby()
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// AcceptChallenge turns the challenge into a room with both players seated.
+func (s *Server) AcceptChallenge(c echo.Context) error {
+	user, _ := currentUser(c)
+	challenge, err := s.takeChallenge(c, func(challenge *Challenge) error {
+		if challenge.Creator.ID == user.ID {
+			return ErrOwnChallenge
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	creatorWhite := challenge.Color == "white" || challenge.Color == "" && rand.IntN(2) == 0
+	room, err := s.NewRoom(challenge.Creator, challenge.TimeControl, challenge.Rated)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	}
+	if err := room.Seat(challenge.Creator, creatorWhite); err != nil {
+		return err
+	}
+	if err := room.Seat(user, !creatorWhite); err != nil {
+		return err
+	}
+	s.saveRoom(room)
+	s.stream.Publish(userTopic(challenge.Creator.ID), Event{Name: "room", Data: strconv.Itoa(room.ID)})
+	s.publishLobby()
+	return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
+}
+
+func (s *Server) CancelChallenge(c echo.Context) error {
+	user, _ := currentUser(c)
+	_, err := s.takeChallenge(c, func(challenge *Challenge) error {
+		if challenge.Creator.ID != user.ID {
+			return ErrNotOwner
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.publishLobby()
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// takeChallenge removes the challenge if check allows it. Removal under
+// the lock makes sure only one user gets it.
+func (s *Server) takeChallenge(c echo.Context, check func(*Challenge) error) (*Challenge, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("id"), err).Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	challenge, ok := s.challenges[id]
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, errors.Join(ErrChallengeNotFound, errors.New(strconv.Itoa(id))).Error())
+	}
+	if err := check(challenge); err != nil {
+		return nil, echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	delete(s.challenges, id)
+	return challenge, nil
+}
+
+func userTopic(id int) string {
+	return "user/" + strconv.Itoa(id)
+}
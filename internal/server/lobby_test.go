@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"ust_chess/internal/server"
+)
+
+func guest(t *testing.T, srv *server.Server, nickname string) *client {
+	t.Helper()
+	c := newClient(t, srv)
+	if rec := c.post("/guest", url.Values{"nickname": {nickname}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("guest %s: %d %s", nickname, rec.Code, rec.Body)
+	}
+	return c
+}
+
+// TestAcceptChallengeRace: of the users accepting one challenge at once
+// only the first gets a game.
+func TestAcceptChallengeRace(t *testing.T) {
+	srv := server.New([]byte("secret"), server.NewMemoryStorage())
+	creator := guest(t, srv, "creator")
+	if rec := creator.post("/challenge", url.Values{"minutes": {"5"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("challenge: %d %s", rec.Code, rec.Body)
+	}
+
+	const accepting = 16
+	clients := make([]*client, accepting)
+	for i := range clients {
+		clients[i] = guest(t, srv, "guest"+strconv.Itoa(i))
+	}
+	codes := make([]int, accepting)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			codes[i] = c.post("/challenge/1/accept", nil).Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	accepted := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusSeeOther:
+			accepted++
+		case http.StatusNotFound:
+		default:
+			t.Errorf("accept: %d", code)
+		}
+	}
+	if rooms := srv.Rooms(); accepted != 1 || len(rooms) != 1 {
+		t.Fatalf("%d accepted, %d rooms, want one of each", accepted, len(rooms))
+	}
+	room := srv.Rooms()[0]
+	if room.White.ID == 0 || room.Black.ID == 0 {
+		t.Fatalf("seats %+v and %+v", room.White, room.Black)
+	}
+}
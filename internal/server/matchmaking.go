@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"ust_chess/internal/board"
+	"ust_chess/internal/matchmaking"
+	"ust_chess/internal/rating"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// PlayNow puts the user into the matchmaking queue of the chosen time
+// control.
+func (s *Server) PlayNow(c echo.Context) error {
+	user, _ := currentUser(c)
+	tc, err := timeControlParam(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	player := s.ratings.Get(user.ID, rating.CategoryOf(tc))
+	ticket := matchmaking.Ticket{
+		UserID:      user.ID,
+		Rating:      int(player.Rating.Rating + 0.5),
+		Provisional: player.IsProvisional(),
+		Pool:        matchmaking.Pool{TimeControl: tc, Variant: board.CLASSIC},
+	}
+	if err := s.matchmaker.Join(ticket); err != nil {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+func (s *Server) CancelPlayNow(c echo.Context) error {
+	user, _ := currentUser(c)
+	s.matchmaker.Cancel(user.ID)
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// onMatch seats a matched pair in a new room and sends both to it. Games
+// between registered users are rated.
+func (s *Server) onMatch(match matchmaking.Match) {
+	white, ok := s.accounts.Get(match.White.UserID)
+	if !ok {
+		return
+	}
+	black, ok := s.accounts.Get(match.Black.UserID)
+	if !ok {
+		return
+	}
+	room, err := s.NewRoom(white, match.Pool.TimeControl, !white.Guest && !black.Guest)
+	if err != nil {
+		log.Error().Err(err).Msg("matchmaking")
+		return
+	}
+	room.mu.Lock()
+	room.Variant = match.Pool.Variant
+	room.mu.Unlock()
+	if err := room.Seat(white, true); err != nil {
+		log.Error().Err(err).Int("room", room.ID).Msg("matchmaking")
+		return
+	}
+	if err := room.Seat(black, false); err != nil {
+		log.Error().Err(err).Int("room", room.ID).Msg("matchmaking")
+		return
+	}
+	s.saveRoom(room)
+	for _, user := range []User{white, black} {
+		s.stream.Publish(userTopic(user.ID), Event{Name: "room", Data: strconv.Itoa(room.ID)})
+	}
+	s.publishLobby()
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"slices"
+	"sync"
+)
+
+// MemoryStorage keeps everything in memory. Useful for tests and for
+// running without a data directory.
+type MemoryStorage struct {
+	mu     sync.Mutex
+	users  map[int]UserRecord
+	rooms  map[int]RoomRecord
+	games  []GameRecord
+	events []GameEvent
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users: make(map[int]UserRecord),
+		rooms: make(map[int]RoomRecord),
+	}
+}
+
+func (m *MemoryStorage) SaveUser(user UserRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *MemoryStorage) Users() ([]UserRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := make([]UserRecord, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *MemoryStorage) SaveRoom(room RoomRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rooms[room.ID] = room
+	return nil
+}
+
+func (m *MemoryStorage) Rooms() ([]RoomRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rooms := make([]RoomRecord, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
+}
+
+func (m *MemoryStorage) SaveGame(game GameRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.games = append(m.games, game)
+	return nil
+}
+
+func (m *MemoryStorage) Games(userID int) ([]GameRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var games []GameRecord
+	for _, game := range m.games {
+		if game.White == userID || game.Black == userID {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (m *MemoryStorage) AppendEvent(event GameEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MemoryStorage) Events() ([]GameEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events), nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
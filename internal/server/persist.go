@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"ust_chess/internal/board"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Load restores users and rooms from the storage. Games are rebuilt by
+// replaying their events, ratings get the settled games they missed and
+// games that ended unsettled are settled. Games suspended by Shutdown resume with the
+// clocks they had, after a crash clocks run on as if the server never
+// stopped. A damaged end of the event log is reported and the games are
+// restored up to it.
+func (s *Server) Load() error {
+	users, err := s.storage.Users()
+	if err != nil {
+		return errors.Join(errors.New("load users"), err)
+	}
+	for _, record := range users {
+		err := s.accounts.Add(User{
+			ID:           record.ID,
+			Name:         record.Name,
+			PasswordHash: record.PasswordHash,
+			Token:        record.Token,
+			SessionKey:   record.SessionKey,
+			Guest:        record.Guest,
+		})
+		if err != nil {
+			return errors.Join(errors.New("load users"), err)
+		}
+		s.ratings.Set(record.ID, record.Ratings, record.RatedAt)
+		if record.SessionKey == "" {
+			s.saveUser(record.ID)
+		}
+	}
+
+	rooms, err := s.storage.Rooms()
+	if err != nil {
+		return errors.Join(errors.New("load rooms"), err)
+	}
+	events, err := s.storage.Events()
+	if errors.Is(err, ErrCorruptLog) {
+		dropped := 0
+		if damage := (*LogDamage)(nil); errors.As(err, &damage) {
+			dropped = damage.Records
+		}
+		log.Error().Err(err).Int("events", len(events)).Int("dropped", dropped).Msg("event log damaged, games restored up to the damage")
+	} else if err != nil {
+		return errors.Join(errors.New("load events"), err)
+	}
+	games := make(map[int][]board.Event)
+	for _, event := range events {
+		if event.Type == board.CREATED {
+			games[event.Room] = nil
+		}
+		games[event.Room] = append(games[event.Room], event.Event)
+		if event.Settlement != nil {
+			s.rate(*event.Settlement, event.At)
+		}
+	}
+
+	now := s.now()
+	for _, record := range rooms {
+		room, err := s.restoreRoom(record, games[record.ID])
+		if err != nil {
+			log.Error().Err(err).Int("room", record.ID).Msg("room not restored")
+			continue
+		}
+		if room.Game.IsSuspended {
+			if err := room.apply(board.Event{Type: board.RESUMED, At: now}); err != nil {
+				log.Error().Err(err).Int("room", room.ID).Msg("game not resumed")
+			}
+		}
+		s.mu.Lock()
+		s.rooms[room.ID] = room
+		s.lastRoomID = max(s.lastRoomID, room.ID)
+		s.mu.Unlock()
+		// Ended before the server could settle it.
+		s.settle(room)
+	}
+	log.Info().Int("users", len(users)).Int("rooms", len(rooms)).Int("events", len(events)).Msg("storage loaded")
+	return nil
+}
+
+func (s *Server) restoreRoom(record RoomRecord, events []board.Event) (*Room, error) {
+	game, err := board.Rebuild(events)
+	if err != nil {
+		return nil, err
+	}
+	room := &Room{
+		ID:             record.ID,
+		Owner:          record.Owner,
+		Game:           game,
+		SpectatorDelay: record.SpectatorDelay,
+		TakebackLimit:  record.TakebackLimit,
+		Variant:        record.Variant,
+		Rated:          record.Rated,
+		Analysis:       record.Analysis,
+		Created:        record.Created,
+		events:         events,
+		log:            s.storage,
+		clock:          s.clock,
+	}
+	for id, seat := range map[int]*User{record.White: &room.White, record.Black: &room.Black} {
+		switch {
+		case id == 0:
+			continue
+		case id < 0:
+			*seat = Bot(-id)
+			continue
+		}
+		user, ok := s.accounts.Get(id)
+		if !ok {
+			return nil, errors.Join(ErrUserNotFound, fmt.Errorf("%d", id))
+		}
+		*seat = user
+	}
+	// Players get their time to come back and to make a first move anew.
+	now := room.now()
+	for _, seat := range []User{room.White, room.Black} {
+		if seat.ID != 0 && !seat.IsBot() {
+			room.markAway(seat.ID, now)
+		}
+	}
+	room.filled = now
+	return room, nil
+}
+
+func (s *Server) saveUser(id int) {
+	user, ok := s.accounts.Get(id)
+	if !ok {
+		return
+	}
+	err := s.storage.SaveUser(UserRecord{
+		ID:           user.ID,
+		Name:         user.Name,
+		PasswordHash: user.PasswordHash,
+		Token:        user.Token,
+		SessionKey:   user.SessionKey,
+		Guest:        user.Guest,
+		Ratings:      s.ratings.All(id),
+		RatedAt:      s.ratings.RatedAt(id),
+	})
+	if err != nil {
+		log.Error().Err(err).Int("user", id).Msg("user not saved")
+	}
+}
+
+func (s *Server) saveRoom(room *Room) {
+	room.mu.Lock()
+	record := room.record()
+	room.mu.Unlock()
+	if err := s.storage.SaveRoom(record); err != nil {
+		log.Error().Err(err).Int("room", record.ID).Msg("room not saved")
+	}
+}
+
+// Shutdown gets the server ready to stop: no new rooms, games in progress
+// are suspended with their clocks and clients are told to come back.
+// Streams are closed, so requests can finish.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	for _, room := range s.Rooms() {
+		s.settle(room)
+		room.mu.Lock()
+		if !room.Game.IsEnded() {
+			if err := room.apply(board.Event{Type: board.SUSPENDED, At: room.now()}); err != nil {
+				log.Error().Err(err).Int("room", room.ID).Msg("game not suspended")
+			}
+		}
+		room.mu.Unlock()
+		s.stream.Publish(roomTopic(room.ID), Event{Name: "restarting"})
+	}
+	s.stream.Publish(lobbyTopic, Event{Name: "restarting"})
+	s.stream.Close()
+}
+
+// Close saves all rooms and closes the storage. Call it after Shutdown,
+// once requests are done.
+func (s *Server) Close() error {
+	for _, room := range s.Rooms() {
+		s.saveRoom(room)
+	}
+	return s.storage.Close()
+}
@@ -0,0 +1,195 @@
+package server_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/rating"
+	"ust_chess/internal/server"
+)
+
+func openServer(t *testing.T, dir string) *server.Server {
+	t.Helper()
+	storage, err := server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New([]byte("secret"), storage)
+	if err := srv.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func stop(t *testing.T, srv *server.Server) {
+	t.Helper()
+	srv.Shutdown()
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSuspendResume: a game running on shutdown goes on after the start
+// with the clocks it had and the same side to move.
+func TestSuspendResume(t *testing.T) {
+	dir := t.TempDir()
+	srv := openServer(t, dir)
+	white, black := newClient(t, srv), newClient(t, srv)
+	white.register("alice")
+	black.register("bob")
+	if rec := white.post("/room", url.Values{"minutes": {"5"}, "increment": {"2"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if rec := black.post("/room/1/join", url.Values{"color": {"black"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("join: %d %s", rec.Code, rec.Body)
+	}
+	white.post("/room/1/move?ix=3&iy=1&fx=3&fy=3", nil)
+	black.post("/room/1/move?ix=3&iy=6&fx=3&fy=4", nil)
+	white.post("/room/1/move?ix=1&iy=0&fx=2&fy=2", nil)
+
+	stop(t, srv)
+	room, err := srv.Room(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := room.Game
+	if !before.IsSuspended || before.Clock.Running || len(before.History) != 3 || !before.IsBlackTurn {
+		t.Fatalf("suspended %v, running %v, %d moves, black to move %v",
+			before.IsSuspended, before.Clock.Running, len(before.History), before.IsBlackTurn)
+	}
+
+	srv = openServer(t, dir)
+	defer stop(t, srv)
+	room, err = srv.Room(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := room.Game
+	if after.IsSuspended || !after.Clock.Running || after.IsBlackTurn != before.IsBlackTurn || len(after.History) != 3 {
+		t.Fatalf("suspended %v, running %v, black to move %v, %d moves",
+			after.IsSuspended, after.Clock.Running, after.IsBlackTurn, len(after.History))
+	}
+	// Replayed times have no monotonic clock reading, which may differ by
+	// a few nanoseconds.
+	if (after.Clock.White-before.Clock.White).Abs() > time.Millisecond || (after.Clock.Black-before.Clock.Black).Abs() > time.Millisecond {
+		t.Fatalf("clocks %v and %v, were %v and %v", after.Clock.White, after.Clock.Black, before.Clock.White, before.Clock.Black)
+	}
+	if left := after.Clock.Remaining(time.Now(), true); left > before.Clock.Black || left < before.Clock.Black-time.Second {
+		t.Fatalf("black has %v left, had %v", left, before.Clock.Black)
+	}
+
+	// The players are back with their cookies.
+	black.e, white.e = newClient(t, srv).e, newClient(t, srv).e
+	black.post("/room/1/move?ix=6&iy=7&fx=5&fy=5", nil)
+	if moves := len(room.Game.History); moves != 4 || room.Game.IsBlackTurn {
+		t.Fatalf("%d moves after resuming", moves)
+	}
+}
+
+// endedGame stores two accounts and a rated blitz room of theirs where
+// black resigned, with the events after it.
+func endedGame(t *testing.T, dir string, after ...server.GameEvent) {
+	t.Helper()
+	storage, err := server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	for _, user := range []server.UserRecord{{ID: 1, Name: "alice", SessionKey: "a"}, {ID: 2, Name: "bob", SessionKey: "b"}} {
+		if err := storage.SaveUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.SaveRoom(server.RoomRecord{ID: 1, Owner: 1, White: 1, Black: 2, Rated: true, TakebackLimit: -1}); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(-time.Minute)
+	blitz := board.TimeControl{Base: 5 * time.Minute}
+	events := append([]server.GameEvent{
+		{Room: 1, Event: board.Event{Type: board.CREATED, At: at, TimeControl: &blitz}},
+		{Room: 1, Event: board.Event{Type: board.RESIGNED, At: at, IsBlack: true}},
+	}, after...)
+	for _, event := range events {
+		if err := storage.AppendEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ratings of alice and bob and the SETTLED events in the log.
+func ratings(t *testing.T, dir string) (rating.Player, rating.Player, int) {
+	t.Helper()
+	srv := openServer(t, dir)
+	c := newClient(t, srv)
+	alice, bob := blitz(t, c, 1), blitz(t, c, 2)
+	stop(t, srv)
+
+	storage, err := server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	events, err := storage.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settled := 0
+	for _, event := range events {
+		if event.Type == board.SETTLED {
+			settled++
+		}
+	}
+	return alice, bob, settled
+}
+
+// TestSettleOnStart: a game that ended right before a crash is rated on
+// start, and only once.
+func TestSettleOnStart(t *testing.T) {
+	dir := t.TempDir()
+	endedGame(t, dir)
+	for range 2 {
+		alice, bob, settled := ratings(t, dir)
+		if alice.Games != 1 || bob.Games != 1 || settled != 1 {
+			t.Fatalf("games %d and %d, settled %d times, want once", alice.Games, bob.Games, settled)
+		}
+		if alice.Rating.Rating <= bob.Rating.Rating {
+			t.Fatalf("winner %.0f, loser %.0f", alice.Rating.Rating, bob.Rating.Rating)
+		}
+	}
+}
+
+// TestRatingsFixedOnStart: a crash after the game was settled and the
+// winner saved leaves the loser to be rated on start.
+func TestRatingsFixedOnStart(t *testing.T) {
+	dir := t.TempDir()
+	settledAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	settlement := &server.Settlement{White: 1, Black: 2, Category: rating.BLITZ, Result: board.WHITE_WON,
+		WhiteBefore: rating.Default(), BlackBefore: rating.Default()}
+	endedGame(t, dir, server.GameEvent{Room: 1, Event: board.Event{Type: board.SETTLED, At: settledAt}, Settlement: settlement})
+	winner := rating.NewPlayer()
+	winner.Play(rating.Default(), 1, settledAt)
+	storage, err := server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.SaveUser(server.UserRecord{ID: 1, Name: "alice", SessionKey: "a",
+		Ratings: map[rating.Category]rating.Player{rating.BLITZ: winner}, RatedAt: settledAt})
+	storage.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loser := rating.NewPlayer()
+	loser.Play(rating.Default(), 0, settledAt)
+	for range 2 {
+		alice, bob, settled := ratings(t, dir)
+		if alice.Games != 1 || alice.Rating != winner.Rating || settled != 1 {
+			t.Fatalf("winner %+v rated again, settled %d times", alice.Rating, settled)
+		}
+		if bob.Games != 1 || bob.Rating != loser.Rating {
+			t.Fatalf("loser %+v, want %+v", bob.Rating, loser.Rating)
+		}
+	}
+}
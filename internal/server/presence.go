@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"time"
+	"ust_chess/internal/board"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// reconnectGrace is how long a seated player may be away before the
+	// opponent can claim the game.
+	reconnectGrace = time.Minute
+	tickInterval   = time.Second
+)
+
+var ErrOpponentHere = errors.New("opponent is connected or may still come back")
+
+// firstMoveTimeout is how long each side may take over its first move
+// before the game is aborted.
+func firstMoveTimeout(tc board.TimeControl) time.Duration {
+	switch {
+	case tc.IsCorrespondence():
+		return tc.PerMove()
+	case !tc.IsTimed():
+		return board.Day
+	}
+	return time.Minute
+}
+
+// Connect counts an open stream of the seated user.
+func (r *Room) Connect(user User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.online == nil {
+		r.online = make(map[int]int)
+	}
+	r.online[user.ID]++
+	delete(r.leftAt, user.ID)
+}
+
+// Disconnect starts the grace period once the last stream of the user
+// is closed.
+func (r *Room) Disconnect(user User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.online[user.ID]--
+	if r.online[user.ID] > 0 {
+		return
+	}
+	delete(r.online, user.ID)
+	r.markAway(user.ID, r.now())
+}
+
+// now is the time of the clock of the room, real time without one.
+func (r *Room) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// markAway notes the user as gone from now. Caller holds the lock.
+func (r *Room) markAway(id int, now time.Time) {
+	if r.leftAt == nil {
+		r.leftAt = make(map[int]time.Time)
+	}
+	r.leftAt[id] = now
+}
+
+// away tells since when the user has no stream open. Caller holds the lock.
+func (r *Room) away(id int) (time.Time, bool) {
+	since, ok := r.leftAt[id]
+	return since, ok && r.online[id] == 0
+}
+
+// graceLeft is the time until the side can claim the game of the opponent
+// who left, zero once it can. It is false while there is nothing to claim.
+// Correspondence players are not expected to stay, their deadlines do the
+// job. Caller holds the lock.
+func (r *Room) graceLeft(isBlack bool, now time.Time) (time.Duration, bool) {
+	game := &r.Game
+	if game.IsEnded() || game.IsPause || len(game.History) < 2 || game.Clock.IsCorrespondence() {
+		return 0, false
+	}
+	opponent := r.Black
+	if isBlack {
+		opponent = r.White
+	}
+	since, away := r.away(opponent.ID)
+	if !away {
+		return 0, false
+	}
+	return max(reconnectGrace-now.Sub(since), 0), true
+}
+
+// ClaimAbandonment ends the game of the opponent who left: a win for the
+// user, or a draw.
+func (r *Room) ClaimAbandonment(user User, draw bool) error {
+	if draw {
+		return r.act(user, board.Event{Type: board.DRAW_CLAIMED, Reason: board.ABANDONMENT})
+	}
+	return r.act(user, board.Event{Type: board.WIN_CLAIMED})
+}
+
+// checkAbort ends the game when a side doesn't make its first move in
+// time. Caller holds the lock.
+func (r *Room) checkAbort(now time.Time) error {
+	game := &r.Game
+	if game.IsEnded() || game.IsPause || len(game.History) >= 2 || r.White.ID == 0 || r.Black.ID == 0 {
+		return nil
+	}
+	since := r.filled
+	if len(game.History) == 1 && game.LastMoveTime.After(since) {
+		since = game.LastMoveTime
+	}
+	if now.Sub(since) < firstMoveTimeout(game.Clock.TimeControl) {
+		return nil
+	}
+	return r.apply(board.Event{Type: board.ABORTED, At: now})
+}
+
+// checkClaimable tells if a claim became possible or impossible since the
+// last check. Caller holds the lock.
+func (r *Room) checkClaimable(now time.Time) bool {
+	white, whiteAway := r.graceLeft(false, now)
+	black, blackAway := r.graceLeft(true, now)
+	claimable := whiteAway && white == 0 || blackAway && black == 0
+	changed := claimable != r.claimable
+	r.claimable = claimable
+	return changed
+}
+
+// tick ends games that ran out of time and tells players when the game of
+// the opponent who left can be claimed. Bots that should move get to it.
+func (s *Server) tick(now time.Time) {
+	for _, room := range s.Rooms() {
+		room.mu.Lock()
+		if err := room.checkAbort(now); err != nil {
+			log.Error().Err(err).Int("room", room.ID).Msg("game not aborted")
+		}
+		changed := room.checkClaimable(now)
+		room.mu.Unlock()
+		s.settle(room)
+		if changed {
+			s.publish(room)
+		}
+		s.playBot(room)
+	}
+}
@@ -0,0 +1,123 @@
+package server_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/server"
+)
+
+// fakeClock stands still until the test moves it. Its timers never fire.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time                         { return c.now }
+func (c *fakeClock) After(d time.Duration) <-chan time.Time { return nil }
+func (c *fakeClock) Advance(d time.Duration)                { c.now = c.now.Add(d) }
+
+// presentGame is a blitz room of alice and bob on the clock, both
+// connected, after 1. e4 e5, and its server.
+func presentGame(t *testing.T, clock *fakeClock) (*server.Server, *server.Room) {
+	t.Helper()
+	srv := server.NewWithClock(clock, []byte("secret"), server.NewMemoryStorage())
+	room, err := srv.NewRoom(alice, board.TimeControl{Base: 5 * time.Minute}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seat := range []struct {
+		user  server.User
+		white bool
+	}{{alice, true}, {bob, false}} {
+		if err := room.Seat(seat.user, seat.white); err != nil {
+			t.Fatal(err)
+		}
+		room.Connect(seat.user)
+	}
+	play(t, room, [4]int{3, 1, 3, 3}, [4]int{3, 6, 3, 4})
+	return srv, room
+}
+
+func TestClaimAbandonment(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	_, room := presentGame(t, clock)
+	if err := room.ClaimAbandonment(alice, false); !errors.Is(err, server.ErrOpponentHere) {
+		t.Fatalf("claim against a connected opponent: %v", err)
+	}
+
+	room.Disconnect(bob)
+	clock.Advance(time.Minute - time.Second)
+	if err := room.ClaimAbandonment(alice, false); !errors.Is(err, server.ErrOpponentHere) {
+		t.Fatalf("claim before the grace period is over: %v", err)
+	}
+	if err := room.ClaimAbandonment(bob, false); !errors.Is(err, server.ErrOpponentHere) {
+		t.Fatalf("claim by the one who left: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := room.ClaimAbandonment(alice, false); err != nil {
+		t.Fatal(err)
+	}
+	if room.Game.Result != board.WHITE_WON || room.Game.Termination != board.ABANDONMENT {
+		t.Fatalf("result %v %v", room.Game.Result, room.Game.Termination)
+	}
+}
+
+func TestClaimAbandonmentDraw(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	_, room := presentGame(t, clock)
+	room.Disconnect(alice)
+	clock.Advance(2 * time.Minute)
+	if err := room.ClaimAbandonment(bob, true); err != nil {
+		t.Fatal(err)
+	}
+	if room.Game.Result != board.DRAW || room.Game.Termination != board.ABANDONMENT {
+		t.Fatalf("result %v %v", room.Game.Result, room.Game.Termination)
+	}
+}
+
+// TestReconnect: a player back within the grace period keeps the game, and
+// a new grace period starts when they leave again.
+func TestReconnect(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	_, room := presentGame(t, clock)
+	room.Disconnect(bob)
+	clock.Advance(30 * time.Second)
+	room.Connect(bob)
+	clock.Advance(time.Minute)
+	if err := room.ClaimAbandonment(alice, false); !errors.Is(err, server.ErrOpponentHere) {
+		t.Fatalf("claim after the opponent came back: %v", err)
+	}
+
+	room.Disconnect(bob)
+	clock.Advance(30 * time.Second)
+	if err := room.ClaimAbandonment(alice, false); !errors.Is(err, server.ErrOpponentHere) {
+		t.Fatalf("claim half way through the new grace period: %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if err := room.ClaimAbandonment(alice, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestShutdownClock: the server suspends and flags games by its own clock,
+// not by the wall clock.
+func TestShutdownClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	srv, room := presentGame(t, clock)
+	clock.Advance(time.Minute)
+	srv.Shutdown()
+	if room.Game.IsEnded() || !room.Game.IsSuspended {
+		t.Fatalf("result %v %v, suspended %v", room.Game.Result, room.Game.Termination, room.Game.IsSuspended)
+	}
+}
+
+func TestSettleClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	srv, room := presentGame(t, clock)
+	clock.Advance(5*time.Minute + time.Second)
+	srv.Shutdown()
+	if room.Game.Result != board.BLACK_WON || room.Game.Termination != board.TIMEOUT || !room.Game.IsSettled {
+		t.Fatalf("result %v %v, settled %v", room.Game.Result, room.Game.Termination, room.Game.IsSettled)
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/rating"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Ratings keeps Glicko-2 ratings of users per time control category.
+type Ratings struct {
+	mu      sync.Mutex
+	players map[int]map[rating.Category]*rating.Player
+	rated   map[int]time.Time // of the last settled game in the ratings of the user
+}
+
+func NewRatings() *Ratings {
+	return &Ratings{
+		players: make(map[int]map[rating.Category]*rating.Player),
+		rated:   make(map[int]time.Time),
+	}
+}
+
+// Get returns a copy of the player's rating, the default one for users
+// who haven't played the category yet.
+func (r *Ratings) Get(userID int, category rating.Category) rating.Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	player, ok := r.players[userID][category]
+	if !ok {
+		return rating.NewPlayer()
+	}
+	copied := *player
+	copied.History = slices.Clone(player.History)
+	return copied
+}
+
+// Settlement is what the SETTLED event of a rated game keeps to rate it:
+// the players and their ratings before the game. Each player is rated on
+// their own against that, so a player whose ratings were not saved yet is
+// rated on start the same way.
+type Settlement struct {
+	White       int
+	Black       int
+	Category    rating.Category
+	Result      board.Result
+	WhiteBefore rating.Rating
+	BlackBefore rating.Rating
+}
+
+// Settlement of a game between the players with the result.
+func (r *Ratings) Settlement(whiteID, blackID int, category rating.Category, result board.Result) Settlement {
+	return Settlement{
+		White:       whiteID,
+		Black:       blackID,
+		Category:    category,
+		Result:      result,
+		WhiteBefore: r.Get(whiteID, category).Rating,
+		BlackBefore: r.Get(blackID, category).Rating,
+	}
+}
+
+// Rate applies the game settled at the time to the players who don't have
+// it in their ratings yet, and returns them.
+func (r *Ratings) Rate(settlement Settlement, at time.Time) []int {
+	score := 0.5
+	switch settlement.Result {
+	case board.WHITE_WON:
+		score = 1
+	case board.BLACK_WON:
+		score = 0
+	case board.DRAW:
+	default:
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rated []int
+	for _, side := range []struct {
+		id       int
+		opponent rating.Rating
+		score    float64
+	}{
+		{settlement.White, settlement.BlackBefore, score},
+		{settlement.Black, settlement.WhiteBefore, 1 - score},
+	} {
+		if !at.After(r.rated[side.id]) {
+			continue
+		}
+		r.player(side.id, settlement.Category).Play(side.opponent, side.score, at)
+		r.rated[side.id] = at
+		rated = append(rated, side.id)
+	}
+	return rated
+}
+
+// RatedAt is the time of the last settled game in the ratings of the user.
+func (r *Ratings) RatedAt(userID int) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rated[userID]
+}
+
+// All returns copies of every rating the user has.
+func (r *Ratings) All(userID int) map[rating.Category]rating.Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make(map[rating.Category]rating.Player, len(r.players[userID]))
+	for category, player := range r.players[userID] {
+		copied := *player
+		copied.History = slices.Clone(player.History)
+		all[category] = copied
+	}
+	return all
+}
+
+// Set replaces ratings of the user, e.g. when loaded from storage.
+func (r *Ratings) Set(userID int, players map[rating.Category]rating.Player, ratedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rated[userID] = ratedAt
+	r.players[userID] = make(map[rating.Category]*rating.Player, len(players))
+	for category, player := range players {
+		r.players[userID][category] = &player
+	}
+}
+
+func (r *Ratings) player(userID int, category rating.Category) *rating.Player {
+	if r.players[userID] == nil {
+		r.players[userID] = make(map[rating.Category]*rating.Player)
+	}
+	player, ok := r.players[userID][category]
+	if !ok {
+		fresh := rating.NewPlayer()
+		player = &fresh
+		r.players[userID][category] = player
+	}
+	return player
+}
+
+type LeaderDto struct {
+	UserID    int
+	Name      string
+	Rating    int
+	Deviation int
+	Games     int
+}
+
+// Leaderboard lists established players of the category, best first.
+// Provisional ratings are too uncertain to be ranked.
+func (r *Ratings) Leaderboard(category rating.Category) []LeaderDto {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leaders := []LeaderDto{}
+	for id, categories := range r.players {
+		player, ok := categories[category]
+		if !ok || player.IsProvisional() {
+			continue
+		}
+		leaders = append(leaders, LeaderDto{
+			UserID:    id,
+			Rating:    int(player.Rating.Rating + 0.5),
+			Deviation: int(player.Deviation + 0.5),
+			Games:     player.Games,
+		})
+	}
+	sort.Slice(leaders, func(i, j int) bool { return leaders[i].Rating > leaders[j].Rating })
+	return leaders
+}
+
+// settle handles the end of the game once: rates it and tells everyone.
+// The SETTLED event is saved first, the ratings follow it. A game that
+// ended without it is settled on start, ratings saved after it are fixed
+// on start from it.
+func (s *Server) settle(room *Room) {
+	now := room.now()
+	room.mu.Lock()
+	room.checkFlag(now)
+	if !room.Game.IsEnded() || room.Game.IsSettled {
+		room.mu.Unlock()
+		return
+	}
+	white, black := room.White, room.Black
+	var settlement *Settlement
+	if room.Rated && white.ID > 0 && black.ID > 0 && !white.Guest && !black.Guest {
+		rated := s.ratings.Settlement(white.ID, black.ID, rating.CategoryOf(room.Game.Clock.TimeControl), room.Game.Result)
+		settlement = &rated
+	}
+	err := room.applySettled(board.Event{Type: board.SETTLED, At: now}, settlement)
+	game := room.gameRecord()
+	room.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	if settlement != nil {
+		s.rate(*settlement, now)
+	}
+	s.saveRoom(room)
+	if err := s.storage.SaveGame(game); err != nil {
+		log.Error().Err(err).Int("room", game.RoomID).Msg("game not saved")
+	}
+	s.publish(room)
+	s.publishLobby()
+}
+
+// rate the players of the settled game who don't have it yet and save
+// them.
+func (s *Server) rate(settlement Settlement, at time.Time) {
+	for _, id := range s.ratings.Rate(settlement, at) {
+		s.saveUser(id)
+	}
+}
+
+type LeaderboardOutDto struct {
+	User       *User
+	Category   rating.Category
+	Categories []rating.Category
+	Leaders    []LeaderDto
+}
+
+func (s *Server) leaderboard(c echo.Context) LeaderboardOutDto {
+	category := rating.Category(c.QueryParam("category"))
+	if !slices.Contains(rating.Categories, category) {
+		category = rating.BLITZ
+	}
+	out := LeaderboardOutDto{
+		Category:   category,
+		Categories: rating.Categories,
+		Leaders:    s.ratings.Leaderboard(category),
+	}
+	for i := range out.Leaders {
+		user, _ := s.accounts.Get(out.Leaders[i].UserID)
+		out.Leaders[i].Name = user.Name
+	}
+	if user, ok := currentUser(c); ok {
+		out.User = &user
+	}
+	return out
+}
+
+func (s *Server) Leaderboard(c echo.Context) error {
+	return c.Render(http.StatusOK, "leaderboard.html", s.leaderboard(c))
+}
+
+func (s *Server) LeaderboardJSON(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.leaderboard(c).Leaders)
+}
+
+// RatingHistory returns ratings of the user with their history for every
+// category, ready to be plotted.
+func (s *Server) RatingHistory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, ErrWrongParameter.Error())
+	}
+	if _, ok := s.accounts.Get(id); !ok {
+		return echo.NewHTTPError(http.StatusNotFound, ErrUserNotFound.Error())
+	}
+	out := make(map[rating.Category]rating.Player, len(rating.Categories))
+	for _, category := range rating.Categories {
+		out[category] = s.ratings.Get(id, category)
+	}
+	return c.JSON(http.StatusOK, out)
+}
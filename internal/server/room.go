@@ -0,0 +1,398 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"time"
+	"ust_chess/internal/analysis"
+	"ust_chess/internal/board"
+	"ust_chess/internal/eval"
+	"ust_chess/internal/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrSeatTaken     = errors.New("seat already taken")
+	ErrAlreadySeated = errors.New("already seated in this room")
+	ErrNotSeated     = errors.New("you are not seated in this room")
+	ErrNotYourPieces = errors.New("not your pieces")
+	ErrNotOwner      = errors.New("only the room owner can do that")
+	ErrNotSaved      = errors.New("not saved, try again")
+	ErrGameNotEnded  = errors.New("game is not over yet")
+	ErrGameStarted   = errors.New("game has started already")
+	ErrNoTakebacks   = errors.New("no takebacks in this rated game")
+	ErrTakebackLimit = errors.New("no takebacks left")
+	ErrRatedAnalysis = errors.New("no analysis in rated games")
+	ErrRatedGuard    = errors.New("no blunder guard in rated games")
+)
+
+func (r *Room) Seat(user User, white bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seat(user, white)
+}
+
+// seat takes the seat for the user. Caller holds the lock.
+func (r *Room) seat(user User, white bool) error {
+	if r.White.ID == user.ID || r.Black.ID == user.ID {
+		return ErrAlreadySeated
+	}
+	seat := &r.Black
+	if white {
+		seat = &r.White
+	}
+	if seat.ID != 0 {
+		return ErrSeatTaken
+	}
+	*seat = user
+	now := r.now()
+	if !user.IsBot() {
+		r.markAway(user.ID, now)
+	}
+	if r.White.ID != 0 && r.Black.ID != 0 {
+		r.filled = now
+	}
+	return nil
+}
+
+func (r *Room) Move(user User, move types.Move) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.White.ID != user.ID && r.Black.ID != user.ID {
+		return ErrNotSeated
+	}
+	if r.Game.IsBlackTurn && r.Black.ID != user.ID ||
+		!r.Game.IsBlackTurn && r.White.ID != user.ID {
+		return ErrNotYourPieces
+	}
+	return r.apply(board.Event{Type: board.MOVED, At: r.now(), Move: &move})
+}
+
+// Blunder tells what the move of the user would give away. Only casual
+// games have the guard.
+func (r *Room) Blunder(user User, move types.Move) (board.Blunder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Rated {
+		return board.Blunder{}, ErrRatedGuard
+	}
+	if r.White.ID != user.ID && r.Black.ID != user.ID {
+		return board.Blunder{}, ErrNotSeated
+	}
+	if r.Game.IsBlackTurn && r.Black.ID != user.ID ||
+		!r.Game.IsBlackTurn && r.White.ID != user.ID {
+		return board.Blunder{}, ErrNotYourPieces
+	}
+	return r.Game.Blunder(move)
+}
+
+// Restart starts a new game in the room once the current one is over.
+func (r *Room) Restart(user User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.White.ID != user.ID && r.Black.ID != user.ID {
+		return ErrNotSeated
+	}
+	if !r.Game.IsEnded() {
+		return ErrGameNotEnded
+	}
+	tc := r.Game.Clock.TimeControl
+	return r.apply(board.Event{Type: board.CREATED, At: r.now(), TimeControl: &tc})
+}
+
+func (r *Room) Resign(user User) error {
+	return r.act(user, board.Event{Type: board.RESIGNED})
+}
+
+// OfferDraw offers a draw to the opponent, or agrees to it if the opponent
+// has offered already.
+func (r *Room) OfferDraw(user User) error {
+	return r.act(user, board.Event{Type: board.DRAW_OFFERED})
+}
+
+func (r *Room) AcceptDraw(user User) error {
+	return r.act(user, board.Event{Type: board.DRAW_ACCEPTED})
+}
+
+func (r *Room) DeclineDraw(user User) error {
+	return r.act(user, board.Event{Type: board.DRAW_DECLINED})
+}
+
+func (r *Room) ClaimDraw(user User, reason board.Termination) error {
+	return r.act(user, board.Event{Type: board.DRAW_CLAIMED, Reason: reason})
+}
+
+// OfferTakeback asks the opponent to take back the last plies: 1 for the
+// user's last move, 2 for the last full move.
+func (r *Room) OfferTakeback(user User, plies int) error {
+	return r.act(user, board.Event{Type: board.TAKEBACK_OFFERED, Plies: plies})
+}
+
+func (r *Room) AcceptTakeback(user User) error {
+	return r.act(user, board.Event{Type: board.TAKEBACK_ACCEPTED})
+}
+
+func (r *Room) DeclineTakeback(user User) error {
+	return r.act(user, board.Event{Type: board.TAKEBACK_DECLINED})
+}
+
+// OfferPause asks the opponent to pause the game, or to go on if it is
+// paused.
+func (r *Room) OfferPause(user User) error {
+	return r.act(user, board.Event{Type: board.PAUSE_OFFERED})
+}
+
+func (r *Room) AcceptPause(user User) error {
+	return r.act(user, board.Event{Type: board.PAUSE_ACCEPTED})
+}
+
+func (r *Room) DeclinePause(user User) error {
+	return r.act(user, board.Event{Type: board.PAUSE_DECLINED})
+}
+
+// OfferAdjourn seals the move of the side to move and asks the opponent to
+// adjourn the game.
+func (r *Room) OfferAdjourn(user User, sealed types.Move) error {
+	return r.act(user, board.Event{Type: board.ADJOURN_OFFERED, Move: &sealed})
+}
+
+// Queue replaces the moves the user plays on their own once the opponent
+// moves. An empty queue cancels them.
+func (r *Room) Queue(user User, queued board.Conditional) error {
+	return r.act(user, board.Event{Type: board.QUEUED, Queued: queued})
+}
+
+// TakeVacation adds days to the deadlines of the user in a correspondence
+// game.
+func (r *Room) TakeVacation(user User, days int) error {
+	return r.act(user, board.Event{Type: board.VACATION, Days: days})
+}
+
+func (r *Room) AcceptAdjourn(user User) error {
+	return r.act(user, board.Event{Type: board.ADJOURN_ACCEPTED})
+}
+
+func (r *Room) DeclineAdjourn(user User) error {
+	return r.act(user, board.Event{Type: board.ADJOURN_DECLINED})
+}
+
+// Ready marks the user back for the adjourned game. The game resumes with
+// the sealed move once both players are ready.
+func (r *Room) Ready(user User) error {
+	return r.act(user, board.Event{Type: board.READY})
+}
+
+// takebackAllowed checks the limit of the room for the side. Casual games
+// have no limit. Caller holds the lock.
+func (r *Room) takebackAllowed(isBlack bool) error {
+	switch {
+	case !r.Rated || r.TakebackLimit < 0:
+		return nil
+	case r.TakebackLimit == 0:
+		return ErrNoTakebacks
+	case r.Game.Takebacks(isBlack) >= r.TakebackLimit:
+		return ErrTakebackLimit
+	}
+	return nil
+}
+
+// act applies the event on behalf of the seated user.
+func (r *Room) act(user User, event board.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.Color(user) {
+	case "white":
+		event.IsBlack = false
+	case "black":
+		event.IsBlack = true
+	default:
+		return ErrNotSeated
+	}
+	switch event.Type {
+	case board.DRAW_OFFERED:
+		if offer := r.Game.DrawOffer; offer.Made && offer.IsBlack != event.IsBlack {
+			event.Type = board.DRAW_ACCEPTED
+		}
+	case board.TAKEBACK_OFFERED:
+		if err := r.takebackAllowed(event.IsBlack); err != nil {
+			return err
+		}
+	case board.TAKEBACK_ACCEPTED:
+		if err := r.takebackAllowed(!event.IsBlack); err != nil {
+			return err
+		}
+	case board.WIN_CLAIMED, board.DRAW_CLAIMED:
+		if event.Type == board.DRAW_CLAIMED && event.Reason != board.ABANDONMENT {
+			break
+		}
+		if left, away := r.graceLeft(event.IsBlack, r.now()); !away || left > 0 {
+			return ErrOpponentHere
+		}
+	}
+	event.At = r.now()
+	return r.apply(event)
+}
+
+// apply plays the event on the game and saves it to the log. The event
+// counts only once it is saved, otherwise the game is rebuilt without it.
+// Caller holds the lock.
+func (r *Room) apply(event board.Event) error {
+	return r.applySettled(event, nil)
+}
+
+// applySettled applies the event, the SETTLED one with the settlement of a
+// rated game. Caller holds the lock.
+func (r *Room) applySettled(event board.Event, settlement *Settlement) error {
+	if err := r.Game.Apply(event); err != nil {
+		return err
+	}
+	if r.log != nil {
+		if err := r.log.AppendEvent(GameEvent{Room: r.ID, Event: event, Settlement: settlement}); err != nil {
+			log.Error().Err(err).Int("room", r.ID).Str("event", string(event.Type)).Msg("event not saved")
+			r.Game, _ = board.Rebuild(r.events)
+			return errors.Join(ErrNotSaved, err)
+		}
+	}
+	if event.Type == board.CREATED {
+		r.events = nil
+		r.filled = event.At
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+// checkFlag ends the game by a FLAG_FELL event once the side to move is out
+// of time. Caller holds the lock.
+func (r *Room) checkFlag(now time.Time) {
+	if !r.Game.IsFlagged(now) {
+		return
+	}
+	if err := r.apply(board.Event{Type: board.FLAG_FELL, At: now, IsBlack: r.Game.IsBlackTurn}); err != nil {
+		log.Error().Err(err).Int("room", r.ID).Msg("flag fall")
+	}
+}
+
+// RoomSettings are the options the owner may change, nil ones stay as
+// they are.
+type RoomSettings struct {
+	SpectatorDelay *int // spectators see the game this many moves behind
+	TakebackLimit  *int // negative for no limit, fixed once a rated game started
+	Analysis       *int // 1 to show the evaluation in casual games, 0 to hide it
+}
+
+// Configure checks all the settings and changes them only if each one can
+// be changed.
+func (r *Room) Configure(user User, settings RoomSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Owner != user.ID {
+		return ErrNotOwner
+	}
+	if limit := settings.TakebackLimit; limit != nil {
+		*limit = max(*limit, -1)
+		if r.Rated && len(r.Game.History) > 0 && !r.Game.IsEnded() && *limit != r.TakebackLimit {
+			return ErrGameStarted
+		}
+	}
+	if on := settings.Analysis; on != nil && r.Rated && *on != 0 {
+		return ErrRatedAnalysis
+	}
+
+	if moves := settings.SpectatorDelay; moves != nil {
+		r.SpectatorDelay = max(*moves, 0)
+	}
+	if limit := settings.TakebackLimit; limit != nil {
+		r.TakebackLimit = *limit
+	}
+	if on := settings.Analysis; on != nil {
+		r.Analysis = *on != 0
+	}
+	return nil
+}
+
+func (r *Room) SetSpectatorDelay(user User, moves int) error {
+	return r.Configure(user, RoomSettings{SpectatorDelay: &moves})
+}
+
+// SetTakebackLimit changes the takeback limit of rated games. It can't
+// change once a rated game has started.
+func (r *Room) SetTakebackLimit(user User, limit int) error {
+	return r.Configure(user, RoomSettings{TakebackLimit: &limit})
+}
+
+// SetAnalysis shows the evaluation of the board in casual games, 1 to turn
+// it on, 0 to turn it off.
+func (r *Room) SetAnalysis(user User, on int) error {
+	return r.Configure(user, RoomSettings{Analysis: &on})
+}
+
+// AddSpectator counts the user as a watcher. Anonymous viewers come as the
+// zero user.
+func (r *Room) AddSpectator(user User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Spectators = append(r.Spectators, user)
+}
+
+func (r *Room) RemoveSpectator(user User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, spectator := range r.Spectators {
+		if spectator.ID == user.ID {
+			r.Spectators = slices.Delete(r.Spectators, i, i+1)
+			return
+		}
+	}
+}
+
+// View is the game as the user is allowed to see it: players get the live
+// game, spectators get it SpectatorDelay moves late. Analysis rooms show
+// the evaluation of the board. Rated games in play don't tell who controls
+// the cells. Caller holds the lock.
+func (r *Room) View(user User) board.GameOutDto {
+	game := r.shown(user)
+	out := game.GetForRender()
+	if r.Analysis {
+		evaluation := eval.Evaluate(&game.Board)
+		out.Eval = &evaluation
+	}
+	if r.Rated && !r.Game.IsEnded() {
+		out.HideControl()
+	}
+	return out
+}
+
+// shown is the game as the user sees it. Caller holds the lock.
+func (r *Room) shown(user User) *board.Game {
+	if r.Color(user) == "" && r.SpectatorDelay > 0 && !r.Game.IsEnded() {
+		shown := max(len(r.Game.History)-r.SpectatorDelay, 0)
+		if delayed, err := board.Replay(r.Game.Clock.TimeControl, r.Game.History[:shown]); err == nil {
+			return &delayed
+		}
+	}
+	return &r.Game
+}
+
+// Motifs on the board the user sees. Rated games show them once over.
+func (r *Room) Motifs(user User) ([]analysis.Motif, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Rated && !r.Game.IsEnded() {
+		return nil, ErrRatedAnalysis
+	}
+	return analysis.Motifs(&r.shown(user).Board), nil
+}
+
+// Color returns seat of the user: "white", "black" or "" for everyone else.
+func (r *Room) Color(user User) string {
+	switch {
+	case user.ID == 0:
+		return ""
+	case r.White.ID == user.ID:
+		return "white"
+	case r.Black.ID == user.ID:
+		return "black"
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/server"
+	"ust_chess/internal/types"
+)
+
+var (
+	alice = server.User{ID: 1, Name: "alice"}
+	bob   = server.User{ID: 2, Name: "bob"}
+	carol = server.User{ID: 3, Name: "carol"}
+)
+
+// newGame is a casual room without clock with alice playing white and bob
+// black.
+func newGame(t *testing.T) *server.Room {
+	t.Helper()
+	srv := server.New([]byte("secret"), server.NewMemoryStorage())
+	room, err := srv.NewRoom(alice, board.TimeControl{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := room.Seat(alice, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := room.Seat(bob, false); err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func play(t *testing.T, room *server.Room, moves ...[4]int) {
+	t.Helper()
+	for i, m := range moves {
+		player := alice
+		if room.Game.IsBlackTurn {
+			player = bob
+		}
+		move, err := types.GetMove(m[0], m[1], m[2], m[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := room.Move(player, move); err != nil {
+			t.Fatalf("move %d: %v", i, err)
+		}
+	}
+}
+
+func TestSpectatorDelay(t *testing.T) {
+	room := newGame(t)
+	if err := room.SetSpectatorDelay(bob, 2); !errors.Is(err, server.ErrNotOwner) {
+		t.Fatalf("delay set by a player who doesn't own the room: %v", err)
+	}
+	if err := room.SetSpectatorDelay(alice, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	play(t, room, [4]int{3, 1, 3, 3}) // e4
+	if moves := room.View(carol).Moves; len(moves) != 0 {
+		t.Fatalf("spectator sees %v ahead of the delay", moves)
+	}
+	play(t, room, [4]int{3, 6, 3, 4}, [4]int{1, 0, 2, 2}, [4]int{6, 7, 5, 5}) // e5 Nf3 Nc6
+	live := room.View(alice).Moves
+	if len(live) != 4 || !slices.Equal(room.View(bob).Moves, live) {
+		t.Fatalf("players see %v and %v", live, room.View(bob).Moves)
+	}
+	for _, spectator := range []server.User{carol, {}} {
+		view := room.View(spectator)
+		if !slices.Equal(view.Moves, live[:2]) {
+			t.Fatalf("spectator %q sees %v, want %v", spectator.Name, view.Moves, live[:2])
+		}
+		if view.IsBlackTurn {
+			t.Fatal("spectator sees black to move after two moves")
+		}
+	}
+
+	if err := room.Resign(bob); err != nil {
+		t.Fatal(err)
+	}
+	if moves := room.View(carol).Moves; !slices.Equal(moves, live) {
+		t.Fatalf("spectator sees %v of the finished game, want %v", moves, live)
+	}
+}
+
+func TestSpectatorMove(t *testing.T) {
+	room := newGame(t)
+	e4, err := types.GetMove(3, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		user server.User
+		want error
+	}{
+		{"spectator", carol, server.ErrNotSeated},
+		{"anonymous", server.User{}, server.ErrNotSeated},
+		{"opponent", bob, server.ErrNotYourPieces},
+	} {
+		if err := room.Move(tt.user, e4); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(room.Game.History) != 0 {
+		t.Fatalf("moves made: %v", room.Game.History)
+	}
+	play(t, room, [4]int{3, 1, 3, 3})
+}
+
+// TestConfigure: settings change together or not at all.
+func TestConfigure(t *testing.T) {
+	srv := server.New([]byte("secret"), server.NewMemoryStorage())
+	room, err := srv.NewRoom(alice, board.TimeControl{Base: 5 * time.Minute}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := room.Seat(alice, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := room.Seat(bob, false); err != nil {
+		t.Fatal(err)
+	}
+	play(t, room, [4]int{3, 1, 3, 3})
+
+	delay, limit, on := 3, 2, 1
+	for _, tt := range []struct {
+		name     string
+		settings server.RoomSettings
+		want     error
+	}{
+		{"takeback limit", server.RoomSettings{SpectatorDelay: &delay, TakebackLimit: &limit}, server.ErrGameStarted},
+		{"analysis", server.RoomSettings{SpectatorDelay: &delay, Analysis: &on}, server.ErrRatedAnalysis},
+	} {
+		if err := room.Configure(alice, tt.settings); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if room.SpectatorDelay != 0 || room.TakebackLimit != -1 || room.Analysis {
+		t.Fatalf("delay %d, limit %d, analysis %v after rejected settings", room.SpectatorDelay, room.TakebackLimit, room.Analysis)
+	}
+	if err := room.Configure(alice, server.RoomSettings{SpectatorDelay: &delay}); err != nil || room.SpectatorDelay != delay {
+		t.Fatalf("delay %d: %v", room.SpectatorDelay, err)
+	}
+}
+
+func TestSettingsForm(t *testing.T) {
+	srv := server.New([]byte("secret"), server.NewMemoryStorage())
+	owner := newClient(t, srv)
+	owner.register("alice")
+	if rec := owner.post("/room", url.Values{"minutes": {"5"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	owner.post("/room/1/settings", url.Values{"spectator_delay": {"2"}, "takeback_limit": {"two"}})
+	room, err := srv.Room(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.SpectatorDelay != 0 {
+		t.Fatalf("delay %d set along with a malformed limit", room.SpectatorDelay)
+	}
+}
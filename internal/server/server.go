@@ -1,29 +1,708 @@
 package server
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/engine"
+	"ust_chess/internal/matchmaking"
+	"ust_chess/internal/rating"
+	"ust_chess/internal/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultVacationDays each player of a correspondence game may take.
+const defaultVacationDays = 14
+
+var (
+	ErrMissingParameter = errors.New("required parameter missing")
+	ErrWrongParameter   = errors.New("wrong value")
+	ErrRoomNotFound     = errors.New("room not found")
+	ErrShuttingDown     = errors.New("server is shutting down")
+)
 
 type Server struct {
-	Port      int
-	Mux       http.ServeMux
-	Templates map[string][]http.HandlerFunc
+	mu              sync.Mutex
+	rooms           map[int]*Room
+	lastRoomID      int
+	challenges      map[int]*Challenge
+	lastChallengeID int
+	accounts        *Accounts
+	sessions        Sessions
+	stream          *StreamServer
+	matchmaker      *matchmaking.Service
+	ratings         *Ratings
+	storage         Storage
+	clock           matchmaking.Clock
+	closing         bool // no new rooms once set
+}
+
+func New(secret []byte, storage Storage) *Server {
+	return NewWithClock(nil, secret, storage)
+}
+
+// NewWithClock is New with the clock of the rooms and the matchmaker.
+// Nil clock means real time.
+func NewWithClock(clock matchmaking.Clock, secret []byte, storage Storage) *Server {
+	s := &Server{
+		rooms:      make(map[int]*Room),
+		challenges: make(map[int]*Challenge),
+		accounts:   NewAccounts(),
+		sessions:   NewSessions(secret),
+		stream:     NewStreamServer(),
+		ratings:    NewRatings(),
+		storage:    storage,
+		clock:      clock,
+	}
+	s.matchmaker = matchmaking.New(clock, matchmaking.DefaultOptions, s.onMatch)
+	return s
+}
+
+// now is the time of the clock of the server, real time without one.
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// Run does the background work of the server until the context is done.
+func (s *Server) Run(ctx context.Context) {
+	go s.matchmaker.Run(ctx)
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(s.now())
+		}
+	}
+}
+
+// Routes registers all handlers of the server.
+func (s *Server) Routes(e *echo.Echo) {
+	e.Use(s.Authenticate)
+	e.Use(s.SameOrigin)
+
+	e.GET("/", s.Index)
+	e.GET("/api/lobby", s.LobbyJSON)
+	e.GET("/lobby/events", s.LobbyEvents)
+	e.POST("/challenge", s.Challenge, s.RequireUser)
+	e.POST("/challenge/:id/accept", s.AcceptChallenge, s.RequireUser)
+	e.POST("/challenge/:id/cancel", s.CancelChallenge, s.RequireUser)
+	e.POST("/play", s.PlayNow, s.RequireUser)
+	e.POST("/play/cancel", s.CancelPlayNow, s.RequireUser)
+	e.GET("/leaderboard", s.Leaderboard)
+	e.GET("/api/leaderboard", s.LeaderboardJSON)
+	e.GET("/api/users/:id/ratings", s.RatingHistory)
+	e.GET("/login", s.LoginPage)
+	e.POST("/login", s.Login)
+	e.GET("/register", s.RegisterPage)
+	e.POST("/register", s.Register)
+	e.POST("/logout", s.Logout)
+	e.POST("/token", s.Token, s.RequireUser)
+	e.POST("/guest", s.Guest)
+	e.GET("/guest/convert", s.ConvertPage, s.RequireUser)
+	e.POST("/guest/convert", s.ConvertGuest, s.RequireUser)
+
+	e.POST("/room", s.Create, s.RequireUser)
+	e.GET("/room/:id", s.Play)
+	e.POST("/room/:id/join", s.EnterRoom, s.RequireUser)
+	e.POST("/room/:id/bot", s.SeatBot, s.RequireUser)
+	e.POST("/room/:id/move", s.Move, s.RequireUser)
+	e.GET("/room/:id/blunder", s.Blunder, s.RequireUser)
+	e.GET("/room/:id/motifs", s.Motifs)
+	e.POST("/room/:id/restart", s.roomAction((*Room).Restart), s.RequireUser)
+	e.POST("/room/:id/resign", s.roomAction((*Room).Resign), s.RequireUser)
+	e.POST("/room/:id/draw/offer", s.roomAction((*Room).OfferDraw), s.RequireUser)
+	e.POST("/room/:id/draw/accept", s.roomAction((*Room).AcceptDraw), s.RequireUser)
+	e.POST("/room/:id/draw/decline", s.roomAction((*Room).DeclineDraw), s.RequireUser)
+	e.POST("/room/:id/draw/claim", s.ClaimDraw, s.RequireUser)
+	e.POST("/room/:id/takeback/offer", s.OfferTakeback, s.RequireUser)
+	e.POST("/room/:id/takeback/accept", s.roomAction((*Room).AcceptTakeback), s.RequireUser)
+	e.POST("/room/:id/takeback/decline", s.roomAction((*Room).DeclineTakeback), s.RequireUser)
+	e.POST("/room/:id/pause/offer", s.roomAction((*Room).OfferPause), s.RequireUser)
+	e.POST("/room/:id/pause/accept", s.roomAction((*Room).AcceptPause), s.RequireUser)
+	e.POST("/room/:id/pause/decline", s.roomAction((*Room).DeclinePause), s.RequireUser)
+	e.POST("/room/:id/adjourn/offer", s.OfferAdjourn, s.RequireUser)
+	e.POST("/room/:id/adjourn/accept", s.roomAction((*Room).AcceptAdjourn), s.RequireUser)
+	e.POST("/room/:id/adjourn/decline", s.roomAction((*Room).DeclineAdjourn), s.RequireUser)
+	e.POST("/room/:id/ready", s.roomAction((*Room).Ready), s.RequireUser)
+	e.POST("/room/:id/abandon/claim", s.ClaimAbandonment, s.RequireUser)
+	e.POST("/room/:id/premove", s.Premove, s.RequireUser)
+	e.POST("/room/:id/queue", s.Queue, s.RequireUser)
+	e.POST("/room/:id/vacation", s.TakeVacation, s.RequireUser)
+	e.GET("/games", s.MyGames, s.RequireUser)
+	e.POST("/room/:id/queue/cancel", s.roomAction(func(room *Room, user User) error {
+		return room.Queue(user, nil)
+	}), s.RequireUser)
+	e.GET("/room/:id/events", s.Events)
+	e.POST("/room/:id/settings", s.Settings, s.RequireUser)
+}
+
+func (s *Server) NewRoom(owner User, tc board.TimeControl, rated bool) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, ErrShuttingDown
+	}
+	room := &Room{
+		ID:            s.lastRoomID + 1,
+		Owner:         owner.ID,
+		TakebackLimit: -1,
+		Rated:         rated,
+		log:           s.storage,
+		clock:         s.clock,
+	}
+	room.Created = room.now()
+	if err := room.apply(board.Event{Type: board.CREATED, At: room.Created, TimeControl: &tc}); err != nil {
+		return nil, err
+	}
+	s.lastRoomID = room.ID
+	s.rooms[room.ID] = room
+	return room, nil
+}
+
+func (s *Server) Room(id int) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return nil, errors.Join(ErrRoomNotFound, errors.New(strconv.Itoa(id)))
+	}
+	return room, nil
+}
+
+func (s *Server) Rooms() []*Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms
+}
+
+// Create makes a new room and seats the creator with the chosen color,
+// and the computer of the "bot" level against them if it's set.
+func (s *Server) Create(c echo.Context) error {
+	user, _ := currentUser(c)
+	tc, err := timeControlParam(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	level := 0
+	if c.FormValue("bot") != "" {
+		if level, err = strconv.Atoi(c.FormValue("bot")); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("bot"), err).Error())
+		}
+		if level < 1 || level > len(engine.Levels) {
+			return echo.NewHTTPError(http.StatusBadRequest, ErrNoSuchLevel.Error())
+		}
+	}
+	room, err := s.NewRoom(user, tc, c.FormValue("rated") != "" && level == 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	}
+	white := c.FormValue("color") != "black"
+	if err := room.Seat(user, white); err != nil {
+		return err
+	}
+	if level != 0 {
+		if err := room.SeatBot(user, !white, level); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		s.playBot(room)
+	}
+	s.saveRoom(room)
+	s.publishLobby()
+	return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
+}
+
+func (s *Server) EnterRoom(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	if err := room.Seat(user, c.FormValue("color") != "black"); err != nil {
+		return s.renderRoom(c, room, err)
+	}
+	s.saveRoom(room)
+	s.publish(room)
+	s.publishLobby()
+	s.playBot(room)
+	return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
+}
+
+// SeatBot seats the computer of the "level" of the form with the "color".
+func (s *Server) SeatBot(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	level, err := strconv.Atoi(c.FormValue("level"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("level"), err).Error())
+	}
+	if err := room.SeatBot(user, c.FormValue("color") != "black", level); err != nil {
+		return s.renderRoom(c, room, err)
+	}
+	s.saveRoom(room)
+	s.publish(room)
+	s.publishLobby()
+	s.playBot(room)
+	return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
 }
 
-func Serve() {
+func (s *Server) Play(c echo.Context) error {
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	return s.renderRoom(c, room, nil)
+}
 
+func (s *Server) Move(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	move, err := moveParam(c)
+	if err != nil {
+		return s.renderRoom(c, room, err)
+	}
+	err = room.Move(user, move)
+	if err == nil {
+		s.publish(room)
+		s.playBot(room)
+	}
+	s.settle(room)
+	return s.renderRoom(c, room, err)
 }
 
-func Index(w http.ResponseWriter, r *http.Request) {
+// Blunder checks the move of the query for the blunder guard: material
+// lost in the exchange and pieces left hanging.
+func (s *Server) Blunder(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	move, err := moveParam(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	blunder, err := room.Blunder(user, move)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, BlunderOutDto{blunder.IsBlunder(), blunder.Exchange, blunder.Hanging})
+}
 
+type BlunderOutDto struct {
+	IsBlunder bool
+	Exchange  int      // material won, lost if negative, in centipawns
+	Hanging   []string // cells of the pieces left hanging
 }
 
-func Play(w http.ResponseWriter, r *http.Request) {
+// Motifs lists the tactical motifs on the board of the room.
+func (s *Server) Motifs(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	motifs, err := room.Motifs(user)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	return c.JSON(http.StatusOK, motifs)
+}
 
+// Premove queues the move of the query to be played right after the
+// opponent moves, or plays it if the opponent already did.
+func (s *Server) Premove(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	move, err := moveParam(c)
+	if err != nil {
+		return s.renderRoom(c, room, err)
+	}
+	err = room.Queue(user, board.Premove(move))
+	if errors.Is(err, board.ErrYourTurn) {
+		// The opponent moved meanwhile.
+		if err = room.Move(user, move); err == nil {
+			s.publish(room)
+		}
+		s.settle(room)
+	}
+	s.playBot(room)
+	return s.renderRoom(c, room, err)
 }
 
-func EnterRoom(w http.ResponseWriter, r *http.Request) {
+// Queue replaces conditional moves with the "lines" of the form, one line
+// per row, e.g. "e7e5 g1f3 b8c6 f1c4".
+func (s *Server) Queue(c echo.Context) error {
+	queued, err := board.ParseConditional(strings.Split(c.FormValue("lines"), "\n"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("lines"), err).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.Queue(user, queued)
+	})(c)
+}
 
+// TakeVacation adds the "days" of the form to the deadlines of the user.
+func (s *Server) TakeVacation(c echo.Context) error {
+	days, err := strconv.Atoi(c.FormValue("days"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("days"), err).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.TakeVacation(user, days)
+	})(c)
 }
 
-func Create(w http.ResponseWriter, r *http.Request) {
+// ClaimDraw claims a draw for the "reason" of the form.
+func (s *Server) ClaimDraw(c echo.Context) error {
+	reason, ok := board.ClaimReason(c.FormValue("reason"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("reason")).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.ClaimDraw(user, reason)
+	})(c)
+}
+
+// ClaimAbandonment ends the game of the opponent who left with the
+// "result" of the form: "win" or "draw".
+func (s *Server) ClaimAbandonment(c echo.Context) error {
+	result := c.FormValue("result")
+	if result != "win" && result != "draw" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("result")).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.ClaimAbandonment(user, result == "draw")
+	})(c)
+}
+
+// OfferTakeback asks to take back the number of "plies" of the form.
+func (s *Server) OfferTakeback(c echo.Context) error {
+	plies, err := strconv.Atoi(c.FormValue("plies"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("plies"), err).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.OfferTakeback(user, plies)
+	})(c)
+}
+
+// OfferAdjourn seals the "move" of the form, e.g. "e2e4".
+func (s *Server) OfferAdjourn(c echo.Context) error {
+	move, err := types.ParseMove(c.FormValue("move"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("move"), err).Error())
+	}
+	return s.roomAction(func(room *Room, user User) error {
+		return room.OfferAdjourn(user, move)
+	})(c)
+}
+
+// roomAction makes a handler for an action of the user in the room. The
+// room is shown again with the error if the action fails.
+func (s *Server) roomAction(action func(room *Room, user User) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, _ := currentUser(c)
+		room, err := s.roomParam(c)
+		if err != nil {
+			return err
+		}
+		if err := action(room, user); err != nil {
+			return s.renderRoom(c, room, err)
+		}
+		s.publish(room)
+		s.settle(room)
+		s.playBot(room)
+		return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
+	}
+}
+
+// Settings changes the room options present in the form.
+func (s *Server) Settings(c echo.Context) error {
+	user, _ := currentUser(c)
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	var settings RoomSettings
+	fields := []struct {
+		name  string
+		value **int
+	}{
+		{"spectator_delay", &settings.SpectatorDelay},
+		{"takeback_limit", &settings.TakebackLimit},
+		{"analysis", &settings.Analysis},
+	}
+	for _, field := range fields {
+		value := c.FormValue(field.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return s.renderRoom(c, room, errors.Join(ErrWrongParameter, errors.New(field.name), err))
+		}
+		*field.value = &n
+	}
+	if err := room.Configure(user, settings); err != nil {
+		return s.renderRoom(c, room, err)
+	}
+	s.saveRoom(room)
+	s.publish(room)
+	return c.Redirect(http.StatusSeeOther, "/room/"+strconv.Itoa(room.ID))
+}
+
+// Events streams room updates. Everyone except the seated players is
+// counted as a spectator while connected.
+func (s *Server) Events(c echo.Context) error {
+	room, err := s.roomParam(c)
+	if err != nil {
+		return err
+	}
+	user, _ := currentUser(c)
+	events, cancel := s.stream.Subscribe(roomTopic(room.ID))
+	defer cancel()
+
+	room.mu.Lock()
+	spectator := room.Color(user) == ""
+	room.mu.Unlock()
+	if spectator {
+		room.AddSpectator(user)
+		s.publish(room)
+		defer func() {
+			room.RemoveSpectator(user)
+			s.publish(room)
+		}()
+	} else {
+		room.Connect(user)
+		s.publish(room)
+		defer func() {
+			room.Disconnect(user)
+			s.publish(room)
+		}()
+	}
+	return Stream(c, events)
+}
+
+func (s *Server) publish(room *Room) {
+	s.stream.Publish(roomTopic(room.ID), Event{Name: "update", Data: strconv.Itoa(room.ID)})
+}
+
+func (s *Server) publishLobby() {
+	s.stream.Publish(lobbyTopic, Event{Name: "update"})
+}
+
+func roomTopic(id int) string {
+	return "room/" + strconv.Itoa(id)
+}
+
+type RoomOutDto struct {
+	board.GameOutDto
+	ID             int
+	White          string
+	Black          string
+	Color          string // seat of the viewer
+	User           *User
+	IsOwner        bool
+	Spectators     int
+	SpectatorDelay int
+	Rated          bool
+	TakebackLimit  int
+	Takebacks      []int  // plies the viewer may ask to take back
+	SealedMove     string // shown only to the player who sealed it
+	MyTurn         bool
+	Ready          bool // viewer is back for the adjourned game
+	WhiteAway      bool
+	BlackAway      bool
+	OpponentAway   bool
+	ClaimIn        int // seconds until the viewer can claim the game of the opponent who left
+	CanClaimWin    bool
+	Queued         []string // moves the viewer queued, as lines of the queue form
+	Deadline       *time.Time
+	VacationLeft   int
+	TimeControl    string
+	WhiteRating    string
+	BlackRating    string
+	CanSeatBot     bool // viewer may seat the computer on an empty seat
+	CanGuard       bool // viewer may check moves for blunders
+	CanStudy       bool // viewer may see the tactics and the control of the board
+	BotLevels      []int
+}
+
+func (s *Server) renderRoom(c echo.Context, room *Room, err error) error {
+	s.settle(room)
+	user, ok := currentUser(c)
+	room.mu.Lock()
+	category := rating.CategoryOf(room.Game.Clock.TimeControl)
+	out := RoomOutDto{
+		GameOutDto:     room.View(user),
+		ID:             room.ID,
+		White:          room.White.Name,
+		Black:          room.Black.Name,
+		Color:          room.Color(user),
+		IsOwner:        ok && room.Owner == user.ID,
+		Spectators:     len(room.Spectators),
+		SpectatorDelay: room.SpectatorDelay,
+		Rated:          room.Rated,
+		TakebackLimit:  room.TakebackLimit,
+		TimeControl:    room.Game.Clock.TimeControl.String(),
+		BotLevels:      botLevels(),
+	}
+	now := room.now()
+	out.CanSeatBot = out.IsOwner && !room.Rated && (len(room.Game.History) == 0 || room.Game.IsEnded())
+	out.CanGuard = out.Color != "" && !room.Rated
+	out.CanStudy = !room.Rated || room.Game.IsEnded()
+	out.MyTurn = out.Color == sideToMove(room.Game.IsBlackTurn)
+	out.Ready = out.Color == "white" && room.Game.IsWhiteReady || out.Color == "black" && room.Game.IsBlackReady
+	if deadline, ok := room.Game.Deadline(now); ok && room.Game.Clock.IsCorrespondence() {
+		out.Deadline = &deadline
+	}
+	if out.Color != "" && room.Game.Clock.IsCorrespondence() {
+		out.VacationLeft = room.Game.VacationLeft(out.Color == "black")
+	}
+	switch out.Color {
+	case "white":
+		out.Queued = room.Game.WhiteQueued.Lines()
+	case "black":
+		out.Queued = room.Game.BlackQueued.Lines()
+	}
+	if sealed := room.Game.SealedMove; sealed != nil && out.MyTurn {
+		out.SealedMove = sealed.Notation()
+	}
+	if out.Color != "" && !room.Game.TakebackOffer.Made && !room.Game.IsPause {
+		isBlack := out.Color == "black"
+		for _, plies := range []int{1, 2} {
+			if room.Game.CanTakeBack(isBlack, plies) && room.takebackAllowed(isBlack) == nil {
+				out.Takebacks = append(out.Takebacks, plies)
+			}
+		}
+	}
+	_, out.WhiteAway = room.away(room.White.ID)
+	_, out.BlackAway = room.away(room.Black.ID)
+	if out.Color != "" {
+		left, away := room.graceLeft(out.Color == "black", now)
+		out.OpponentAway = away
+		out.ClaimIn = int(left.Round(time.Second).Seconds())
+		out.CanClaimWin = away && left == 0
+	}
+	room.mu.Unlock()
+	if out.White != "" && !room.White.IsBot() {
+		out.WhiteRating = s.ratings.Get(room.White.ID, category).Rating.String()
+	}
+	if out.Black != "" && !room.Black.IsBot() {
+		out.BlackRating = s.ratings.Get(room.Black.ID, category).Rating.String()
+	}
+	if ok {
+		out.User = &user
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return c.Render(http.StatusOK, "board.html", out)
+}
+
+func sideToMove(isBlackTurn bool) string {
+	if isBlackTurn {
+		return "black"
+	}
+	return "white"
+}
+
+func (s *Server) roomParam(c echo.Context) (*Room, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Join(ErrWrongParameter, errors.New("id"), err).Error())
+	}
+	room, err := s.Room(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return room, nil
+}
+
+func moveParam(c echo.Context) (types.Move, error) {
+	var coords [4]int
+	for i, name := range []string{"ix", "iy", "fx", "fy"} {
+		n, err := intParam(c, name)
+		if err != nil {
+			return types.Move{}, err
+		}
+		coords[i] = n
+	}
+	return types.GetMove(coords[0], coords[1], coords[2], coords[3])
+}
+
+// timeControlParam reads "minutes" and "increment" (seconds) of the form.
+// Empty minutes mean a game without clock. "days" per move make it a
+// correspondence game with "vacation" days for each player.
+func timeControlParam(c echo.Context) (board.TimeControl, error) {
+	tc := board.TimeControl{}
+	if days := c.FormValue("days"); days != "" && days != "0" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return tc, errors.Join(ErrWrongParameter, errors.New("days"), err)
+		}
+		tc.DaysPerMove, tc.VacationDays = n, defaultVacationDays
+		if vacation := c.FormValue("vacation"); vacation != "" {
+			n, err := strconv.Atoi(vacation)
+			if err != nil || n < 0 {
+				return tc, errors.Join(ErrWrongParameter, errors.New("vacation"), err)
+			}
+			tc.VacationDays = n
+		}
+		return tc, nil
+	}
+	if minutes := c.FormValue("minutes"); minutes != "" && minutes != "0" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil || n < 0 {
+			return tc, errors.Join(ErrWrongParameter, errors.New("minutes"), err)
+		}
+		tc.Base = time.Duration(n) * time.Minute
+	}
+	if increment := c.FormValue("increment"); increment != "" {
+		n, err := strconv.Atoi(increment)
+		if err != nil || n < 0 {
+			return tc, errors.Join(ErrWrongParameter, errors.New("increment"), err)
+		}
+		tc.Increment = time.Duration(n) * time.Second
+	}
+	return tc, nil
+}
 
+func intParam(c echo.Context, name string) (int, error) {
+	str := c.FormValue(name)
+	if str == "" {
+		return 0, errors.Join(ErrMissingParameter, errors.New(name))
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, errors.Join(ErrWrongParameter, errors.New(name), err)
+	}
+	return n, nil
 }
@@ -1,19 +1,152 @@
 package server
 
 import (
+	"errors"
+	"sync"
+	"time"
 	"ust_chess/internal/board"
+	"ust_chess/internal/matchmaking"
+	"ust_chess/internal/rating"
 )
 
 type Room struct {
-	ID         int
-	Game       board.Game
-	White      User
-	Black      User
-	Spectators []User
+	mu             sync.Mutex
+	ID             int
+	Owner          int // id of the user who created the room
+	Game           board.Game
+	White          User
+	Black          User
+	Spectators     []User
+	SpectatorDelay int // spectators see the game this many moves behind
+	TakebackLimit  int // takebacks each player may get in rated games, negative for no limit
+	Variant        board.Variant
+	Rated          bool
+	Analysis       bool // players and spectators see the evaluation of the board
+	Created        time.Time
+	filled         time.Time   // when both seats were taken
+	online         map[int]int // open streams of players by user id
+	leftAt         map[int]time.Time
+	claimable      bool          // a player can claim the game of the one who left
+	thinking       bool          // a bot searches for its move
+	events         []board.Event // of the current game, Game is built from them
+	log            EventLog      // where events are saved before they count
+	clock          matchmaking.Clock
+}
+
+// EventLog receives every event of every game.
+type EventLog interface {
+	AppendEvent(event GameEvent) error
+}
+
+// GameEvent is an event of the game played in the room.
+type GameEvent struct {
+	Room int
+	board.Event
+	Settlement *Settlement `json:",omitempty"` // of the SETTLED event of a rated game
 }
 
 type User struct {
-	ID     int
-	Name   string
-	Tocken string
+	ID           int
+	Name         string
+	PasswordHash []byte `json:"-"`
+	Token        string `json:"-"` // sha256 of the API token
+	SessionKey   string `json:"-"` // signed into session cookies
+	Guest        bool
+}
+
+var ErrNotFound = errors.New("not found")
+
+// Storage keeps everything the server must not forget on restart.
+type Storage interface {
+	SaveUser(user UserRecord) error
+	Users() ([]UserRecord, error)
+	SaveRoom(room RoomRecord) error
+	Rooms() ([]RoomRecord, error)
+	SaveGame(game GameRecord) error
+	Games(userID int) ([]GameRecord, error)
+	// AppendEvent returns once the event is stored. Events come back in
+	// the same order. A damaged end of the log is reported with
+	// ErrCorruptLog along with the events before it.
+	AppendEvent(event GameEvent) error
+	Events() ([]GameEvent, error)
+	Close() error
+}
+
+// UserRecord is the stored form of a user with secrets and ratings.
+type UserRecord struct {
+	ID           int
+	Name         string
+	PasswordHash []byte
+	Token        string
+	SessionKey   string
+	Guest        bool
+	Ratings      map[rating.Category]rating.Player
+	RatedAt      time.Time // of the last settled game in the ratings
+}
+
+// RoomRecord is the stored form of a room. The game is kept in the event
+// log.
+type RoomRecord struct {
+	ID             int
+	Owner          int
+	White          int
+	Black          int
+	Variant        board.Variant
+	Rated          bool
+	Analysis       bool
+	Created        time.Time
+	SpectatorDelay int
+	TakebackLimit  int
+}
+
+// GameRecord is a finished game.
+type GameRecord struct {
+	RoomID      int
+	White       int
+	Black       int
+	WhiteName   string
+	BlackName   string
+	TimeControl board.TimeControl
+	Rated       bool
+	Result      board.Result
+	Termination board.Termination
+	Moves       []string
+	Ended       time.Time
+}
+
+// record of the room state. Caller holds the lock.
+func (r *Room) record() RoomRecord {
+	return RoomRecord{
+		ID:             r.ID,
+		Owner:          r.Owner,
+		White:          r.White.ID,
+		Black:          r.Black.ID,
+		Variant:        r.Variant,
+		Rated:          r.Rated,
+		Analysis:       r.Analysis,
+		Created:        r.Created,
+		SpectatorDelay: r.SpectatorDelay,
+		TakebackLimit:  r.TakebackLimit,
+	}
+}
+
+// gameRecord of the finished game. Caller holds the lock.
+func (r *Room) gameRecord() GameRecord {
+	moves := make([]string, len(r.Game.History))
+	for i, record := range r.Game.History {
+		moves[i] = record.Move.Notation()
+	}
+	return GameRecord{
+		RoomID:      r.ID,
+		White:       r.White.ID,
+		Black:       r.Black.ID,
+		WhiteName:   r.White.Name,
+		BlackName:   r.Black.Name,
+		TimeControl: r.Game.Clock.TimeControl,
+		Rated:       r.Rated,
+		Result:      r.Game.Result,
+		Termination: r.Game.Termination,
+		Moves:       moves,
+		Ended:       r.Game.LastMoveTime,
+	}
 }
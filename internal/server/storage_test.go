@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/rating"
+	"ust_chess/internal/server"
+	"ust_chess/internal/types"
+)
+
+// backends opens the storage anew each time the function is called, on
+// the same data.
+var backends = map[string]func(t *testing.T) func() server.Storage{
+	"memory": func(t *testing.T) func() server.Storage {
+		storage := server.NewMemoryStorage()
+		return func() server.Storage { return storage }
+	},
+	"file": func(t *testing.T) func() server.Storage {
+		dir := t.TempDir()
+		return func() server.Storage {
+			storage, err := server.NewFileStorage(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return storage
+		}
+	},
+}
+
+func TestStorageRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	blitz := board.TimeControl{Base: 5 * time.Minute, Increment: 3 * time.Second}
+	e4, err := types.GetMove(3, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := rating.NewPlayer()
+	player.Play(rating.Default(), 1, at)
+	users := []server.UserRecord{
+		{ID: 1, Name: "alice", PasswordHash: []byte("hash"), Token: "token", SessionKey: "key",
+			Ratings: map[rating.Category]rating.Player{rating.BLITZ: player}},
+		{ID: 2, Name: "ghost", SessionKey: "other", Guest: true},
+	}
+	rooms := []server.RoomRecord{
+		{ID: 1, Owner: 1, White: 1, Black: 2, Rated: true, Created: at, SpectatorDelay: 2, TakebackLimit: -1},
+		{ID: 2, Owner: 2, White: -3, Analysis: true, Created: at.Add(time.Hour)},
+	}
+	games := []server.GameRecord{
+		{RoomID: 1, White: 1, Black: 2, WhiteName: "alice", BlackName: "ghost", TimeControl: blitz, Rated: true,
+			Result: board.WHITE_WON, Termination: board.RESIGNATION, Moves: []string{"e2e4"}, Ended: at},
+	}
+	events := []server.GameEvent{
+		{Room: 1, Event: board.Event{Type: board.CREATED, At: at, TimeControl: &blitz}},
+		{Room: 1, Event: board.Event{Type: board.MOVED, At: at.Add(time.Second), Move: &e4}},
+		{Room: 2, Event: board.Event{Type: board.CREATED, At: at, TimeControl: &board.TimeControl{}}},
+		{Room: 1, Event: board.Event{Type: board.RESIGNED, At: at.Add(2 * time.Second), IsBlack: true}},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			open := backend(t)
+			storage := open()
+			for _, user := range users {
+				if err := storage.SaveUser(user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, room := range rooms {
+				if err := storage.SaveRoom(room); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, game := range games {
+				if err := storage.SaveGame(game); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, event := range events {
+				if err := storage.AppendEvent(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := storage.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			storage = open()
+			defer storage.Close()
+			gotUsers, err := storage.Users()
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.SortFunc(gotUsers, func(a, b server.UserRecord) int { return a.ID - b.ID })
+			equal(t, "users", gotUsers, users)
+			gotRooms, err := storage.Rooms()
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.SortFunc(gotRooms, func(a, b server.RoomRecord) int { return a.ID - b.ID })
+			equal(t, "rooms", gotRooms, rooms)
+			for _, id := range []int{1, 2} {
+				gotGames, err := storage.Games(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				equal(t, "games", gotGames, games)
+			}
+			if gotGames, err := storage.Games(3); err != nil || len(gotGames) != 0 {
+				t.Fatalf("games of a user who played none: %v, %v", gotGames, err)
+			}
+			gotEvents, err := storage.Events()
+			if err != nil {
+				t.Fatal(err)
+			}
+			equal(t, "events", gotEvents, events)
+		})
+	}
+}
+
+func equal[T any](t *testing.T, what string, got, want T) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s:\n%+v\nwant\n%+v", what, got, want)
+	}
+}
+
+// TestStorageUndecodableEvent: an event with a valid checksum that can't
+// be decoded is cut off the log like a damaged one.
+func TestStorageUndecodableEvent(t *testing.T) {
+	dir := t.TempDir()
+	wal, _, err := server.OpenWAL(filepath.Join(dir, "events.wal"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := json.Marshal(server.GameEvent{Room: 1, Event: board.Event{Type: board.CREATED}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{string(created), `{"Room":`, string(created)} {
+		if err := wal.Append([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal.Close()
+
+	storage, err := server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := storage.Events()
+	var damage *server.LogDamage
+	if !errors.Is(err, server.ErrCorruptLog) || !errors.As(err, &damage) || damage.Records != 1 {
+		t.Fatalf("error %v, want ErrCorruptLog with one more record dropped", err)
+	}
+	if len(events) != 1 || events[0].Room != 1 {
+		t.Fatalf("events %+v, want the one before the damage", events)
+	}
+	if corrupt, _ := filepath.Glob(filepath.Join(dir, "events.wal.*.corrupt")); len(corrupt) != 1 {
+		t.Fatalf("damage not kept: %v", corrupt)
+	}
+	if err := storage.AppendEvent(server.GameEvent{Room: 2, Event: board.Event{Type: board.CREATED}}); err != nil {
+		t.Fatal(err)
+	}
+	storage.Close()
+
+	storage, err = server.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	events, err = storage.Events()
+	if err != nil || len(events) != 2 || events[1].Room != 2 {
+		t.Fatalf("events %+v, %v after reopening", events, err)
+	}
+}
@@ -1,4 +1,108 @@
 package server
 
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Event sent to browsers over server-sent events.
+type Event struct {
+	Name string
+	Data string
+}
+
+// StreamServer fans events out to subscribers of a topic.
 type StreamServer struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+	closed      bool
+}
+
+func NewStreamServer() *StreamServer {
+	return &StreamServer{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe returns one channel for events of all the given topics.
+func (s *StreamServer) Subscribe(topics ...string) (<-chan Event, func()) {
+	ch := make(chan Event, 8)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	for _, topic := range topics {
+		if s.subscribers[topic] == nil {
+			s.subscribers[topic] = make(map[chan Event]struct{})
+		}
+		s.subscribers[topic][ch] = struct{}{}
+	}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		for _, topic := range topics {
+			delete(s.subscribers[topic], ch)
+			if len(s.subscribers[topic]) == 0 {
+				delete(s.subscribers, topic)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Publish never blocks: a subscriber that is too slow misses the event.
+// Every event makes the page refetch its state anyway.
+func (s *StreamServer) Publish(topic string, event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close ends all streams. Events already published are still delivered.
+func (s *StreamServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channels := make(map[chan Event]struct{})
+	for _, subscribers := range s.subscribers {
+		for ch := range subscribers {
+			channels[ch] = struct{}{}
+		}
+	}
+	for ch := range channels {
+		close(ch)
+	}
+	s.subscribers = make(map[string]map[chan Event]struct{})
+	s.closed = true
+}
+
+// Stream writes events of the channel to the response until the client
+// goes away or the channel is closed.
+func Stream(c echo.Context, events <-chan Event) error {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data); err != nil {
+				return err
+			}
+			w.Flush()
+		}
+	}
 }
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrCorruptLog = errors.New("corrupt log")
+
+const (
+	walHeaderSize = 8
+	walMaxRecord  = 1 << 20
+)
+
+// WAL is an append-only file of records. Every record is framed as
+//
+//	length uint32 | crc32 of payload uint32 | payload
+//
+// both numbers big endian, so a torn or damaged write is found on reading.
+type WAL struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// LogDamage tells what was cut off a damaged log.
+type LogDamage struct {
+	Offset  int    // of the first damaged record
+	Bytes   int    // cut off from there on
+	Records int    // valid records after the damaged one, cut off with it
+	File    string // where the cut bytes went
+}
+
+func (d *LogDamage) Error() string {
+	return fmt.Sprintf("%d bytes from offset %d with %d valid records moved to %s", d.Bytes, d.Offset, d.Records, d.File)
+}
+
+// OpenWAL opens the log, creating it if needed, and reads all records. A
+// truncated or corrupt record, or one check rejects, cuts the log, so new
+// records follow the last valid one. check may be nil. The cut bytes are
+// moved to a ".corrupt" file next to the log and reported with
+// ErrCorruptLog and a LogDamage together with the valid records.
+func OpenWAL(path string, check func(record []byte) error) (*WAL, [][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	records, valid, tailErr := readRecords(data, check)
+	if tailErr != nil {
+		damage := &LogDamage{
+			Offset:  valid,
+			Bytes:   len(data) - valid,
+			Records: droppedRecords(data, valid, check),
+			File:    fmt.Sprintf("%s.%d.corrupt", path, time.Now().Unix()),
+		}
+		if err := os.WriteFile(damage.File, data[valid:], 0o644); err != nil {
+			return nil, nil, errors.Join(tailErr, err)
+		}
+		if err := os.Truncate(path, int64(valid)); err != nil {
+			return nil, nil, errors.Join(tailErr, err)
+		}
+		tailErr = errors.Join(tailErr, damage)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &WAL{file: file}, records, tailErr
+}
+
+// readRecords returns payloads of the valid records and the length of the
+// data they take.
+func readRecords(data []byte, check func([]byte) error) ([][]byte, int, error) {
+	var records [][]byte
+	offset := 0
+	for offset < len(data) {
+		record, end, err := readRecord(data, offset, check)
+		if err != nil {
+			return records, offset, err
+		}
+		records = append(records, record)
+		offset = end
+	}
+	return records, offset, nil
+}
+
+// readRecord at the offset, with the offset of the next one.
+func readRecord(data []byte, offset int, check func([]byte) error) ([]byte, int, error) {
+	if len(data)-offset < walHeaderSize {
+		return nil, 0, errors.Join(ErrCorruptLog, fmt.Errorf("truncated header at offset %d", offset))
+	}
+	length := binary.BigEndian.Uint32(data[offset:])
+	sum := binary.BigEndian.Uint32(data[offset+4:])
+	if length > walMaxRecord {
+		return nil, 0, errors.Join(ErrCorruptLog, fmt.Errorf("record of %d bytes at offset %d", length, offset))
+	}
+	start := offset + walHeaderSize
+	end := start + int(length)
+	if end > len(data) {
+		return nil, 0, errors.Join(ErrCorruptLog, fmt.Errorf("truncated record at offset %d", offset))
+	}
+	if crc32.ChecksumIEEE(data[start:end]) != sum {
+		return nil, 0, errors.Join(ErrCorruptLog, fmt.Errorf("checksum mismatch at offset %d", offset))
+	}
+	if check != nil {
+		if err := check(data[start:end]); err != nil {
+			return nil, 0, errors.Join(ErrCorruptLog, fmt.Errorf("bad record at offset %d", offset), err)
+		}
+	}
+	return data[start:end], end, nil
+}
+
+// droppedRecords counts the valid records following the damaged one at
+// the offset. Past a damaged length nothing can be told apart.
+func droppedRecords(data []byte, offset int, check func([]byte) error) int {
+	if len(data)-offset < walHeaderSize {
+		return 0
+	}
+	end := offset + walHeaderSize + int(binary.BigEndian.Uint32(data[offset:]))
+	if end > len(data) {
+		return 0
+	}
+	n := 0
+	for offset = end; offset < len(data); n++ {
+		_, next, err := readRecord(data, offset, check)
+		if err != nil {
+			break
+		}
+		offset = next
+	}
+	return n
+}
+
+// Append writes the record and returns once it is synced to disk. A failed
+// write is cut off, so it can't hide the records written after it.
+func (w *WAL) Append(payload []byte) error {
+	if len(payload) > walMaxRecord {
+		return fmt.Errorf("record of %d bytes is too big", len(payload))
+	}
+	frame := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+	frame = append(frame, payload...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	size, err := w.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(frame); err != nil {
+		return errors.Join(err, w.file.Truncate(size))
+	}
+	if err := w.file.Sync(); err != nil {
+		return errors.Join(err, w.file.Truncate(size))
+	}
+	return nil
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,152 @@
+package server_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"ust_chess/internal/server"
+)
+
+func openWAL(t *testing.T, path string) (*server.WAL, []string, error) {
+	t.Helper()
+	wal, records, err := server.OpenWAL(path, nil)
+	if wal == nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	var out []string
+	for _, record := range records {
+		out = append(out, string(record))
+	}
+	return wal, out, err
+}
+
+func writeRecords(t *testing.T, path string, records ...string) {
+	t.Helper()
+	wal, _, err := openWAL(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := wal.Append([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWALRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wal")
+	writeRecords(t, path, "one", "two", "three")
+
+	_, records, err := openWAL(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two", "three"}; !slices.Equal(records, want) {
+		t.Errorf("records %q, want %q", records, want)
+	}
+}
+
+func TestWALDamagedTail(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+	}{
+		{"torn header", func(data []byte) []byte { return append(data, 0, 0, 0) }},
+		{"torn record", func(data []byte) []byte { return data[:len(data)-2] }},
+		{"flipped byte", func(data []byte) []byte { data[len(data)-1] ^= 0xff; return data }},
+		{"garbage length", func(data []byte) []byte { return append(data, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "test.wal")
+			writeRecords(t, path, "one", "two")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.damage(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			wal, records, err := openWAL(t, path)
+			if !errors.Is(err, server.ErrCorruptLog) {
+				t.Fatalf("error %v, want ErrCorruptLog", err)
+			}
+			if len(records) == 0 || records[0] != "one" {
+				t.Fatalf("records %q, want the valid ones", records)
+			}
+			if corrupt, _ := filepath.Glob(path + ".*.corrupt"); len(corrupt) != 1 {
+				t.Errorf("damaged tail not kept: %v", corrupt)
+			}
+
+			// New records follow the valid ones.
+			if err := wal.Append([]byte("three")); err != nil {
+				t.Fatal(err)
+			}
+			wal.Close()
+			_, after, err := openWAL(t, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := append(records, "three"); !slices.Equal(after, want) {
+				t.Errorf("records %q, want %q", after, want)
+			}
+		})
+	}
+}
+
+// TestWALDamageInTheMiddle: the log is cut at the damage, the valid
+// records after it are counted.
+func TestWALDamageInTheMiddle(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   func([]byte) error
+		damage  func(data []byte) []byte
+		dropped int
+	}{
+		{"flipped byte", nil, func(data []byte) []byte { data[8+3+8] ^= 0xff; return data }, 2},
+		{"garbage length", nil, func(data []byte) []byte { data[8+3] = 0xff; return data }, 0},
+		{"rejected", func(record []byte) error {
+			if string(record) == "two" {
+				return errors.New("bad record")
+			}
+			return nil
+		}, func(data []byte) []byte { return data }, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.wal")
+			writeRecords(t, path, "one", "two", "six", "ten")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.damage(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			wal, records, err := server.OpenWAL(path, tt.check)
+			if wal == nil {
+				t.Fatal(err)
+			}
+			defer wal.Close()
+			var damage *server.LogDamage
+			if !errors.Is(err, server.ErrCorruptLog) || !errors.As(err, &damage) {
+				t.Fatalf("error %v, want ErrCorruptLog with the damage", err)
+			}
+			if len(records) != 1 || string(records[0]) != "one" {
+				t.Fatalf("records %q, want the one before the damage", records)
+			}
+			if damage.Offset != 8+3 || damage.Bytes != len(data)-damage.Offset || damage.Records != tt.dropped {
+				t.Errorf("damage %+v, want %d records dropped", damage, tt.dropped)
+			}
+			if kept, err := os.ReadFile(damage.File); err != nil || len(kept) != damage.Bytes {
+				t.Errorf("cut bytes not kept: %d, %v", len(kept), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package types
+
+// Attacked tells if a piece of the color attacks the cell.
+func (b *Board) Attacked(pos Position, byWhite bool) bool {
+	for _, piece := range b.GetCell(pos).targetedBy {
+		if piece.IsWhite() == byWhite {
+			return true
+		}
+	}
+	return false
+}
+
+// King of the color, nil if there is none.
+func (b *Board) King(white bool) *Piece {
+	kings := b.bitboards.Of(KING, white)
+	if kings == 0 {
+		return nil
+	}
+	return b.GetCell(PosOf(kings.First())).piece
+}
+
+// Checkers are the pieces giving check to the king of the color.
+func (b *Board) Checkers(white bool) []*Piece {
+	king := b.King(white)
+	if king == nil {
+		return nil
+	}
+	var checkers []*Piece
+	for _, piece := range b.GetCell(king.position).targetedBy {
+		if piece.IsWhite() != white {
+			checkers = append(checkers, piece)
+		}
+	}
+	return checkers
+}
+
+func (b *Board) InCheck(white bool) bool {
+	return len(b.Checkers(white)) > 0
+}
+
+// KeepsKingSafe tells if the move, one the piece may make by its rules,
+// leaves the own king out of check. Castling and en passant are left to
+// the caller: the first is checked when it is generated, the second takes
+// a piece off another cell.
+func (b *Board) KeepsKingSafe(move Move) bool {
+	piece := b.GetCell(move.GetInitial()).piece
+	king := b.King(piece.IsWhite())
+	if king == nil {
+		return true
+	}
+	checkers := b.Checkers(piece.IsWhite())
+	final := move.GetFinal()
+
+	if piece == king {
+		if b.Attacked(final, !piece.IsWhite()) {
+			return false
+		}
+		// A line through the king still goes on once it steps back along it.
+		for _, checker := range checkers {
+			if behind, ok := next(checker.position, king.position); ok && checker.slides() && final == behind {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case len(checkers) > 1:
+		return false
+	case len(checkers) == 1:
+		checker := checkers[0]
+		if final != checker.position && !(checker.slides() && between(checker.position, king.position, final)) {
+			return false
+		}
+	}
+	if pinner := b.pinner(piece, king); pinner != nil {
+		return final == pinner.position || between(pinner.position, king.position, final)
+	}
+	return true
+}
+
+// pinner is the sliding piece that would attack the king if the piece
+// left its line.
+func (b *Board) pinner(piece, king *Piece) *Piece {
+	for _, attacker := range b.GetCell(piece.position).targetedBy {
+		if attacker.IsWhite() == piece.IsWhite() || !attacker.slides() {
+			continue
+		}
+		for pos, ok := next(attacker.position, piece.position); ok; pos, ok = next(piece.position, pos) {
+			if behind := b.GetCell(pos).piece; behind != nil {
+				if behind == king {
+					return attacker
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Piece) slides() bool {
+	return p.figure == QUEEN || p.figure == ROOK || p.figure == BISHOP
+}
+
+// next cell after to on the line from from, false off the board. The
+// cells are on one line.
+func next(from, to Position) (Position, bool) {
+	pos, err := NewPos(to.x+sign(to.x-from.x), to.y+sign(to.y-from.y))
+	return pos, err == nil
+}
+
+// between tells if the cell is on the line from a to b, strictly inside.
+func between(a, b, cell Position) bool {
+	dx, dy := b.x-a.x, b.y-a.y
+	if dx != 0 && dy != 0 && iAbs(dx) != iAbs(dy) {
+		return false
+	}
+	for pos := a; pos != b; {
+		pos = Position{pos.x + sign(dx), pos.y + sign(dy)}
+		if pos == cell && pos != b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package types_test
+
+import (
+	"slices"
+	"testing"
+	"ust_chess/internal/types"
+)
+
+// Move generation went from scanning cells one by one to attack tables and
+// bitboards. On kiwipete, the scan took:
+//
+//	LegalMoves     8030 ns
+//	Perft depth 3  18.9 ms
+//	Attacked x64   2970 ns
+//
+// BenchmarkAttackedScan keeps that scan as a reference, compare it with
+// BenchmarkAttacked: go test -run - -bench Attacked ./internal/types
+
+const kiwipete = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -"
+
+func BenchmarkLegalMoves(b *testing.B) {
+	pos, err := types.ParseFEN(kiwipete)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		pos.LegalMoves()
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	pos, err := types.ParseFEN(kiwipete)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		perft(&pos, 3)
+	}
+}
+
+func BenchmarkAttacked(b *testing.B) {
+	pos, err := types.ParseFEN(kiwipete)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		for x := range 8 {
+			for y := range 8 {
+				pos.Attacked(types.MustNewPos(x, y), false)
+			}
+		}
+	}
+}
+
+// scanAttacked is how attacks were found before bitboards: from the cell,
+// step to where each figure could attack it from.
+func scanAttacked(b *types.Board, x, y int, byWhite bool) bool {
+	onBoard := func(x, y int) bool {
+		return x >= 0 && x < 8 && y >= 0 && y < 8
+	}
+	is := func(x, y int, figures ...types.Figure) bool {
+		if !onBoard(x, y) {
+			return false
+		}
+		piece := b.GetCell(types.MustNewPos(x, y)).GetPiece()
+		return piece != nil && piece.IsWhite() == byWhite && slices.Contains(figures, piece.GetType())
+	}
+	empty := func(x, y int) bool {
+		return onBoard(x, y) && b.GetCell(types.MustNewPos(x, y)).GetPiece() == nil
+	}
+	pawnY := y - 1
+	if !byWhite {
+		pawnY = y + 1
+	}
+	if is(x-1, pawnY, types.PAWN) || is(x+1, pawnY, types.PAWN) {
+		return true
+	}
+	for _, step := range [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}} {
+		if is(x+step[0], y+step[1], types.KNIGHT) {
+			return true
+		}
+	}
+	for _, step := range [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}} {
+		if is(x+step[0], y+step[1], types.KING) {
+			return true
+		}
+	}
+	slide := func(steps [][2]int, figures ...types.Figure) bool {
+		for _, step := range steps {
+			cx, cy := x+step[0], y+step[1]
+			for empty(cx, cy) {
+				cx, cy = cx+step[0], cy+step[1]
+			}
+			if is(cx, cy, figures...) {
+				return true
+			}
+		}
+		return false
+	}
+	return slide([][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}, types.ROOK, types.QUEEN) ||
+		slide([][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}, types.BISHOP, types.QUEEN)
+}
+
+func BenchmarkAttackedScan(b *testing.B) {
+	pos, err := types.ParseFEN(kiwipete)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for x := range 8 {
+		for y := range 8 {
+			for _, white := range []bool{true, false} {
+				if scanAttacked(&pos, x, y, white) != pos.Attacked(types.MustNewPos(x, y), white) {
+					b.Fatalf("%s attacked by white %v", types.MustNewPos(x, y).Notation(), white)
+				}
+			}
+		}
+	}
+	b.ResetTimer()
+	for range b.N {
+		for x := range 8 {
+			for y := range 8 {
+				scanAttacked(&pos, x, y, false)
+			}
+		}
+	}
+}
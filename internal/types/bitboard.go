@@ -0,0 +1,216 @@
+package types
+
+import "math/bits"
+
+// Bitboard is a set of cells, one bit per cell: bit y*8+x for the cell
+// (x, y).
+type Bitboard uint64
+
+// Square is the number of the cell's bit in a bitboard.
+func (p Position) Square() int {
+	return p.y*8 + p.x
+}
+
+// PosOf the square.
+func PosOf(square int) Position {
+	return Position{square % 8, square / 8}
+}
+
+func BitOf(pos Position) Bitboard {
+	return 1 << pos.Square()
+}
+
+func (b Bitboard) Has(square int) bool {
+	return b&(1<<square) != 0
+}
+
+func (b Bitboard) Count() int {
+	return bits.OnesCount64(uint64(b))
+}
+
+// First square of the set, 64 if it is empty.
+func (b Bitboard) First() int {
+	return bits.TrailingZeros64(uint64(b))
+}
+
+// Pop takes the first square out of the set.
+func (b *Bitboard) Pop() int {
+	square := b.First()
+	*b &= *b - 1
+	return square
+}
+
+var (
+	knightSteps = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps   = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+)
+
+// directionSteps of the directions of sliding pieces.
+var directionSteps = [...][2]int{
+	UP:         {0, 1},
+	LEFT_UP:    {-1, 1},
+	LEFT:       {-1, 0},
+	LEFT_DOWN:  {-1, -1},
+	DOWN:       {0, -1},
+	RIGHT_DOWN: {1, -1},
+	RIGHT:      {1, 0},
+	RIGHT_UP:   {1, 1},
+}
+
+var (
+	knightAttacks [64]Bitboard
+	kingAttacks   [64]Bitboard
+	pawnAttacks   [2][64]Bitboard                   // by color, white second
+	rays          [len(directionSteps)][64]Bitboard // cells from the square to the edge, by direction
+)
+
+func init() {
+	cells := func(square int, steps [][2]int, slide bool) Bitboard {
+		var set Bitboard
+		x, y := square%8, square/8
+		for _, step := range steps {
+			for i := 1; ; i++ {
+				pos, err := NewPos(x+i*step[0], y+i*step[1])
+				if err != nil {
+					break
+				}
+				set |= BitOf(pos)
+				if !slide {
+					break
+				}
+			}
+		}
+		return set
+	}
+	for square := range 64 {
+		knightAttacks[square] = cells(square, knightSteps, false)
+		kingAttacks[square] = cells(square, kingSteps, false)
+		pawnAttacks[1][square] = cells(square, [][2]int{{-1, 1}, {1, 1}}, false)
+		pawnAttacks[0][square] = cells(square, [][2]int{{-1, -1}, {1, -1}}, false)
+		for direction := UP; direction <= RIGHT_UP; direction++ {
+			rays[direction][square] = cells(square, [][2]int{directionSteps[direction]}, true)
+		}
+	}
+}
+
+func side(white bool) int {
+	if white {
+		return 1
+	}
+	return 0
+}
+
+func KnightAttacks(square int) Bitboard {
+	return knightAttacks[square]
+}
+
+func KingAttacks(square int) Bitboard {
+	return kingAttacks[square]
+}
+
+// PawnAttacks of a pawn of the color on the square.
+func PawnAttacks(white bool, square int) Bitboard {
+	return pawnAttacks[side(white)][square]
+}
+
+// ray from the square up to the first occupied cell, that one included.
+func ray(direction Direction, square int, occupied Bitboard) Bitboard {
+	attacks := rays[direction][square]
+	blockers := attacks & occupied
+	if blockers == 0 {
+		return attacks
+	}
+	return attacks ^ rays[direction][nearest(direction, blockers)]
+}
+
+// nearest square of the set in the direction: the lowest one on the way
+// to higher squares, the highest one otherwise.
+func nearest(direction Direction, set Bitboard) int {
+	if step := directionSteps[direction]; step[1]*8+step[0] > 0 {
+		return set.First()
+	}
+	return 63 - bits.LeadingZeros64(uint64(set))
+}
+
+func RookAttacks(square int, occupied Bitboard) Bitboard {
+	return ray(UP, square, occupied) | ray(RIGHT, square, occupied) |
+		ray(DOWN, square, occupied) | ray(LEFT, square, occupied)
+}
+
+func BishopAttacks(square int, occupied Bitboard) Bitboard {
+	return ray(RIGHT_UP, square, occupied) | ray(LEFT_UP, square, occupied) |
+		ray(RIGHT_DOWN, square, occupied) | ray(LEFT_DOWN, square, occupied)
+}
+
+func QueenAttacks(square int, occupied Bitboard) Bitboard {
+	return RookAttacks(square, occupied) | BishopAttacks(square, occupied)
+}
+
+// Attacks of the figure of the color from the square.
+func Attacks(figure Figure, white bool, square int, occupied Bitboard) Bitboard {
+	switch figure {
+	case PAWN:
+		return PawnAttacks(white, square)
+	case KNIGHT:
+		return KnightAttacks(square)
+	case KING:
+		return KingAttacks(square)
+	case BISHOP:
+		return BishopAttacks(square, occupied)
+	case ROOK:
+		return RookAttacks(square, occupied)
+	case QUEEN:
+		return QueenAttacks(square, occupied)
+	}
+	return 0
+}
+
+// Bitboards of the pieces by color, white second, and figure.
+type Bitboards struct {
+	Figures [2][6]Bitboard // by color, then by figure from KING
+	Colors  [2]Bitboard
+}
+
+func (b *Bitboards) Of(figure Figure, white bool) Bitboard {
+	return b.Figures[side(white)][figure-KING]
+}
+
+func (b *Bitboards) Occupied() Bitboard {
+	return b.Colors[0] | b.Colors[1]
+}
+
+func (b *Bitboards) Color(white bool) Bitboard {
+	return b.Colors[side(white)]
+}
+
+// Put the piece on the square, the square must be empty.
+func (b *Bitboards) Put(figure Figure, white bool, square int) {
+	bit := Bitboard(1) << square
+	b.Figures[side(white)][figure-KING] |= bit
+	b.Colors[side(white)] |= bit
+}
+
+// Remove the piece from the square.
+func (b *Bitboards) Remove(figure Figure, white bool, square int) {
+	bit := ^(Bitboard(1) << square)
+	b.Figures[side(white)][figure-KING] &= bit
+	b.Colors[side(white)] &= bit
+}
+
+// AttackersOf the square among the pieces of the color.
+func (b *Bitboards) AttackersOf(square int, white bool) Bitboard {
+	occupied := b.Occupied()
+	queens := b.Of(QUEEN, white)
+	return PawnAttacks(!white, square)&b.Of(PAWN, white) |
+		KnightAttacks(square)&b.Of(KNIGHT, white) |
+		KingAttacks(square)&b.Of(KING, white) |
+		BishopAttacks(square, occupied)&(b.Of(BISHOP, white)|queens) |
+		RookAttacks(square, occupied)&(b.Of(ROOK, white)|queens)
+}
+
+// MoveOf the piece between two different squares. The squares need no
+// checks unlike the coordinates of GetMove.
+func MoveOf(from, to int) Move {
+	initial, final := PosOf(from), PosOf(to)
+	return Move{posInit: initial, posFinal: final, Direction: getMoveDirection(initial.x, initial.y, final.x, final.y)}
+}
@@ -10,48 +10,157 @@ type Cell struct {
 	targetedBy []*Piece
 }
 
+// Board keeps the pieces in bitboards. Cells are a view of them, with the
+// pieces and the ones attacking every cell. The side to move and the
+// rights it has go along with the pieces.
 type Board struct {
-	board  [8][8]Cell
-	pieces map[bool][]Piece
+	bitboards     Bitboards
+	board         [8][8]Cell
+	isBlackTurn   bool
+	castling      Castling
+	enPassant     int // file of a pawn that just made a double step, -1 if none
+	halfmoveClock int // moves since the last capture or pawn move
 }
 
+// GetBoard with the pieces, white to move. Castling is allowed for kings
+// and rooks on their starting cells.
 func GetBoard(initialPieces []Piece) (Board, error) {
-	board := Board{}
-	board.pieces = make(map[bool][]Piece, len(initialPieces))
+	board := Board{enPassant: -1}
 	for _, piece := range initialPieces {
-		board.pieces[piece.IsWhite()] = append(board.pieces[piece.IsWhite()], piece)
-		board.GetCell(piece.position).piece =
-			&board.pieces[piece.IsWhite()][len(board.pieces[piece.IsWhite()])-1]
+		// Every cell gets its own copy of the piece. Attacks are noted
+		// anew for this board.
+		piece.targetedCells = nil
+		board.GetCell(piece.position).piece = &piece
+		board.bitboards.Put(piece.figure, piece.isWhite, piece.position.Square())
 	}
+	for x := range board.board {
+		for y := range board.board[x] {
+			if piece := board.board[x][y].piece; piece != nil {
+				piece.MarkTargetedCells(&board)
+			}
+		}
+	}
+	board.castling = board.castlingRights()
 	return board, nil
 }
 
+func (b *Board) IsBlackTurn() bool {
+	return b.isBlackTurn
+}
+
+// Castling rights left.
+func (b *Board) Castling() Castling {
+	return b.castling
+}
+
+// EnPassant is the file of a pawn that just made a double step, -1 if
+// none.
+func (b *Board) EnPassant() int {
+	return b.enPassant
+}
+
+// HalfmoveClock counts moves since the last capture or pawn move.
+func (b *Board) HalfmoveClock() int {
+	return b.halfmoveClock
+}
+
+// MakeMove moves the piece, taking the one on the final cell and
+// promoting a pawn if the move says so. Rules are not checked and the
+// side to move stays, Make plays a whole move.
 func (b *Board) MakeMove(move Move) {
-	targetCell := b.GetCell(move.GetFinal())
-	if targetCell.piece != nil {
-		targetCell.piece.isTaken = true
+	initial, final := b.GetCell(move.GetInitial()), b.GetCell(move.GetFinal())
+	// Only these see farther or less far now.
+	sliders := b.sliders(initial, final)
+	if taken := final.piece; taken != nil {
+		taken.isTaken = true
+		taken.ClearTargetedCells(b)
+		b.bitboards.Remove(taken.figure, taken.isWhite, taken.position.Square())
+	}
+	piece := initial.piece
+	b.bitboards.Remove(piece.figure, piece.isWhite, piece.position.Square())
+	final.piece = piece
+	piece.position = move.GetFinal()
+	if move.Promotion != 0 {
+		piece.figure = move.Promotion
+	}
+	initial.piece = nil
+	b.bitboards.Put(piece.figure, piece.isWhite, piece.position.Square())
+	piece.MarkTargetedCells(b)
+	b.remark(sliders, piece)
+}
+
+// Take removes the piece from the board, e.g. a pawn taken en passant.
+func (b *Board) Take(pos Position) {
+	cell := b.GetCell(pos)
+	if cell.piece == nil {
+		return
+	}
+	sliders := b.sliders(cell)
+	cell.piece.isTaken = true
+	cell.piece.ClearTargetedCells(b)
+	b.bitboards.Remove(cell.piece.figure, cell.piece.isWhite, pos.Square())
+	cell.piece = nil
+	b.remark(sliders, nil)
+}
+
+// sliders are the sliding pieces attacking the cells: their lines change
+// when a piece comes or goes there.
+func (b *Board) sliders(cells ...*Cell) []*Piece {
+	var sliders []*Piece
+	for _, cell := range cells {
+		for _, piece := range cell.targetedBy {
+			if piece.figure == QUEEN || piece.figure == ROOK || piece.figure == BISHOP {
+				sliders = append(sliders, piece)
+			}
+		}
+	}
+	return sliders
+}
+
+// remark notes again the attacks of the pieces still on the board except
+// the one already done.
+func (b *Board) remark(pieces []*Piece, done *Piece) {
+	for _, piece := range pieces {
+		if piece != done && !piece.isTaken {
+			piece.MarkTargetedCells(b)
+		}
 	}
-	targetCell.piece = b.GetCell(move.GetInitial()).piece
-	targetCell.piece.position = move.GetFinal()
-	b.GetCell(move.GetInitial()).piece = nil
 }
 
 func (b *Board) GetCell(pos Position) *Cell {
 	return &b.board[pos.GetX()][pos.GetY()]
 }
 
+// GetPieces on the board by color.
 func (b *Board) GetPieces(pos Position) map[bool][]Piece {
-	return b.pieces
+	pieces := make(map[bool][]Piece, 2)
+	for _, white := range []bool{true, false} {
+		for set := b.bitboards.Color(white); set != 0; {
+			piece := b.GetCell(PosOf(set.Pop())).piece
+			pieces[white] = append(pieces[white], *piece)
+		}
+	}
+	return pieces
+}
+
+// Bitboards of the pieces on the board.
+func (b *Board) Bitboards() Bitboards {
+	return b.bitboards
 }
 
 func (c *Cell) GetPiece() *Piece {
 	return c.piece
 }
 
+// GetTargetedBy returns pieces of both colors attacking the cell.
+func (c *Cell) GetTargetedBy() []*Piece {
+	return c.targetedBy
+}
+
 func (c *Cell) RemoveFromTargetedBy(pieceId int) {
 	for i, piece := range c.targetedBy {
 		if piece.GetId() == pieceId {
-			c.targetedBy = slices.Delete(c.targetedBy, i, i)
+			c.targetedBy = slices.Delete(c.targetedBy, i, i+1)
 			return
 		}
 	}
@@ -0,0 +1,63 @@
+package types
+
+// Castling rights left in the game.
+type Castling uint8
+
+const (
+	WHITE_KINGSIDE Castling = 1 << iota
+	WHITE_QUEENSIDE
+	BLACK_KINGSIDE
+	BLACK_QUEENSIDE
+)
+
+// Files of the king and the rooks at the start. The kingside is towards
+// x=0, the h-file.
+const (
+	kingFile      = 3
+	kingsideRook  = 0
+	queensideRook = 7
+)
+
+// castlingLost are the rights gone once a piece moves from or to the cell.
+func castlingLost(pos Position) Castling {
+	switch {
+	case pos.x == kingFile && pos.y == 0:
+		return WHITE_KINGSIDE | WHITE_QUEENSIDE
+	case pos.x == kingFile && pos.y == 7:
+		return BLACK_KINGSIDE | BLACK_QUEENSIDE
+	case pos.x == kingsideRook && pos.y == 0:
+		return WHITE_KINGSIDE
+	case pos.x == queensideRook && pos.y == 0:
+		return WHITE_QUEENSIDE
+	case pos.x == kingsideRook && pos.y == 7:
+		return BLACK_KINGSIDE
+	case pos.x == queensideRook && pos.y == 7:
+		return BLACK_QUEENSIDE
+	}
+	return 0
+}
+
+// castlingRights of kings and rooks standing where they start.
+func (b *Board) castlingRights() Castling {
+	is := func(x, y int, figure Figure, white bool) bool {
+		piece := b.board[x][y].piece
+		return piece != nil && piece.figure == figure && piece.isWhite == white
+	}
+	var rights Castling
+	for _, side := range []struct {
+		rank  int
+		white bool
+		rook  int
+		right Castling
+	}{
+		{0, true, kingsideRook, WHITE_KINGSIDE},
+		{0, true, queensideRook, WHITE_QUEENSIDE},
+		{7, false, kingsideRook, BLACK_KINGSIDE},
+		{7, false, queensideRook, BLACK_QUEENSIDE},
+	} {
+		if is(kingFile, side.rank, KING, side.white) && is(side.rook, side.rank, ROOK, side.white) {
+			rights |= side.right
+		}
+	}
+	return rights
+}
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrBadFEN = errors.New("bad FEN")
+
+// StartFEN is the position of a classic game.
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var (
+	fenFigures = map[byte]Figure{'k': KING, 'q': QUEEN, 'r': ROOK, 'b': BISHOP, 'n': KNIGHT, 'p': PAWN}
+	fenRights  = map[byte]Castling{'K': WHITE_KINGSIDE, 'Q': WHITE_QUEENSIDE, 'k': BLACK_KINGSIDE, 'q': BLACK_QUEENSIDE}
+)
+
+// ParseFEN reads a position in Forsyth-Edwards Notation. The move
+// counters may be left out.
+func ParseFEN(fen string) (Board, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("%d fields", len(fields)))
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("%d ranks", len(ranks)))
+	}
+	var pieces []Piece
+	kings := map[bool]int{}
+	for i, rank := range ranks {
+		y, file := 7-i, 0
+		for j := 0; j < len(rank); j++ {
+			c := rank[j]
+			if c >= '1' && c <= '8' {
+				file += int(c - '0')
+				continue
+			}
+			figure, ok := fenFigures[c|0x20]
+			if !ok || file > 7 {
+				return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("rank %d", y+1))
+			}
+			white := c < 'a'
+			pieces = append(pieces, MustNewPiece(figure, white, MustNewPos(7-file, y)))
+			if figure == KING {
+				kings[white]++
+			}
+			file++
+		}
+		if file != 8 {
+			return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("rank %d", y+1))
+		}
+	}
+	if kings[true] != 1 || kings[false] != 1 {
+		return Board{}, errors.Join(ErrBadFEN, errors.New("each side needs one king"))
+	}
+	board, err := GetBoard(pieces)
+	if err != nil {
+		return Board{}, errors.Join(ErrBadFEN, err)
+	}
+
+	switch fields[1] {
+	case "w":
+	case "b":
+		board.isBlackTurn = true
+	default:
+		return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("side %s", fields[1]))
+	}
+
+	board.castling = 0
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			right, ok := fenRights[fields[2][i]]
+			if !ok {
+				return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("castling %s", fields[2]))
+			}
+			board.castling |= right
+		}
+	}
+	// Rights without the king and the rook on their cells are lost.
+	board.castling &= board.castlingRights()
+
+	if fields[3] != "-" {
+		square, err := ParsePos(fields[3])
+		if err != nil {
+			return Board{}, errors.Join(ErrBadFEN, err)
+		}
+		board.enPassant = square.GetX()
+	}
+
+	if len(fields) == 6 {
+		clock, err := strconv.Atoi(fields[4])
+		if err != nil || clock < 0 {
+			return Board{}, errors.Join(ErrBadFEN, fmt.Errorf("halfmove clock %s", fields[4]))
+		}
+		board.halfmoveClock = clock
+	}
+	return board, nil
+}
+
+// FEN of the board. Boards don't count full moves, so the move number is
+// always 1.
+func (b *Board) FEN() string {
+	var out strings.Builder
+	for y := 7; y >= 0; y-- {
+		empty := 0
+		for x := 7; x >= 0; x-- {
+			piece := b.board[x][y].piece
+			if piece == nil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				out.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			out.WriteByte(fenLetter(piece))
+		}
+		if empty > 0 {
+			out.WriteByte(byte('0' + empty))
+		}
+		if y > 0 {
+			out.WriteByte('/')
+		}
+	}
+
+	side := "w"
+	if b.isBlackTurn {
+		side = "b"
+	}
+	rights := ""
+	for _, letter := range []byte("KQkq") {
+		if b.castling&fenRights[letter] != 0 {
+			rights += string(letter)
+		}
+	}
+	if rights == "" {
+		rights = "-"
+	}
+	enPassant := "-"
+	if b.enPassant >= 0 {
+		// the cell the pawn passed
+		y := 2
+		if !b.isBlackTurn {
+			y = 5
+		}
+		enPassant = MustNewPos(b.enPassant, y).Notation()
+	}
+	return fmt.Sprintf("%s %s %s %s %d 1", out.String(), side, rights, enPassant, b.halfmoveClock)
+}
+
+func fenLetter(piece *Piece) byte {
+	for letter, figure := range fenFigures {
+		if figure != piece.figure {
+			continue
+		}
+		if piece.isWhite {
+			return letter &^ 0x20
+		}
+		return letter
+	}
+	return '?'
+}
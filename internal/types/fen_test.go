@@ -0,0 +1,70 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+	"ust_chess/internal/types"
+)
+
+func TestFEN(t *testing.T) {
+	b, err := types.ParseFEN(types.StartFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := b.FEN(); fen != types.StartFEN {
+		t.Errorf("start position is %s", fen)
+	}
+	for _, notation := range []string{"e2e4", "c7c5", "g1f3"} {
+		move, err := types.ParseMove(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Make(move)
+	}
+	want := "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 1"
+	if fen := b.FEN(); fen != want {
+		t.Errorf("got %s, want %s", fen, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq -",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w KQkq -",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq -",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KX -",
+	} {
+		if _, err := types.ParseFEN(bad); !errors.Is(err, types.ErrBadFEN) {
+			t.Errorf("%q: %v", bad, err)
+		}
+	}
+}
+
+// TestFENCastling drops rights the pieces on the board can't have, so no
+// castling is played without its king and rook.
+func TestFENCastling(t *testing.T) {
+	tests := []struct {
+		fen, want string
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", "4k3/8/8/8/8/8/8/4K3 w - - 0 1"},
+		{"r3k3/8/8/8/8/8/8/3K3R w KQkq - 0 1", "r3k3/8/8/8/8/8/8/3K3R w q - 0 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"},
+	}
+	castle, err := types.ParseMove("e1g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		b, err := types.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fen := b.FEN(); fen != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.fen, fen, tt.want)
+		}
+		_, err = b.Legal(castle)
+		if castles := b.Castling()&types.WHITE_KINGSIDE != 0; castles != (err == nil) {
+			t.Errorf("%s: castling %v: %v", tt.fen, castles, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package types
+
+// Undo is what Make changed on the board, for Unmake to take it back.
+type Undo struct {
+	move          Move
+	figure        Figure // of the moved piece before the move, a pawn if it was promoted
+	captured      *Piece // on the final cell or, en passant, beside it
+	rook          *Move  // of a castling
+	castling      Castling
+	enPassant     int
+	halfmoveClock int
+}
+
+// Make plays the move with what comes along: a king going two files
+// castles with the rook on that side, a pawn going diagonally to an empty
+// cell takes en passant. Rules are not checked. The other side is to move
+// after it, with the rights left.
+func (b *Board) Make(move Move) Undo {
+	initial, final := move.GetInitial(), move.GetFinal()
+	piece := b.GetCell(initial).piece
+	undo := Undo{move: move, figure: piece.figure, captured: b.GetCell(final).piece,
+		castling: b.castling, enPassant: b.enPassant, halfmoveClock: b.halfmoveClock}
+	switch {
+	case piece.figure == KING && iAbs(final.x-initial.x) == 2:
+		from := kingsideRook
+		if final.x > initial.x {
+			from = queensideRook
+		}
+		rook := MoveOf(MustNewPos(from, initial.y).Square(), MustNewPos((initial.x+final.x)/2, initial.y).Square())
+		undo.rook = &rook
+		b.MakeMove(rook)
+	case piece.figure == PAWN && initial.x != final.x && undo.captured == nil:
+		taken := MustNewPos(final.x, initial.y)
+		undo.captured = b.GetCell(taken).piece
+		b.Take(taken)
+	}
+	b.MakeMove(move)
+
+	b.castling &^= castlingLost(initial) | castlingLost(final)
+	b.enPassant = -1
+	if undo.figure == PAWN && iAbs(final.y-initial.y) == 2 {
+		b.enPassant = final.x
+	}
+	b.halfmoveClock++
+	if undo.figure == PAWN || undo.captured != nil {
+		b.halfmoveClock = 0
+	}
+	b.isBlackTurn = !b.isBlackTurn
+	return undo
+}
+
+// Unmake takes back the last move Make played. The pieces, the captured
+// one too, are where they were and attack what they did, the side that
+// moved is to move again with the rights it had.
+func (b *Board) Unmake(undo Undo) {
+	initial, final := undo.move.GetInitial(), undo.move.GetFinal()
+	if piece := b.GetCell(final).piece; piece.figure != undo.figure {
+		b.bitboards.Remove(piece.figure, piece.isWhite, final.Square())
+		piece.figure = undo.figure
+		b.bitboards.Put(piece.figure, piece.isWhite, final.Square())
+	}
+	b.MakeMove(MoveOf(final.Square(), initial.Square()))
+	if undo.rook != nil {
+		b.MakeMove(MoveOf(undo.rook.GetFinal().Square(), undo.rook.GetInitial().Square()))
+	}
+	if undo.captured != nil {
+		b.put(undo.captured)
+	}
+	b.castling, b.enPassant, b.halfmoveClock = undo.castling, undo.enPassant, undo.halfmoveClock
+	b.isBlackTurn = !b.isBlackTurn
+}
+
+// put the piece back on its cell, which must be empty.
+func (b *Board) put(piece *Piece) {
+	cell := b.GetCell(piece.position)
+	sliders := b.sliders(cell)
+	cell.piece = piece
+	piece.isTaken = false
+	b.bitboards.Put(piece.figure, piece.isWhite, piece.position.Square())
+	piece.MarkTargetedCells(b)
+	b.remark(sliders, piece)
+}
+
+// Clone is a copy of the board with pieces of its own: moves on one do
+// not change the other.
+func (b *Board) Clone() Board {
+	clone := *b
+	clone.board = [8][8]Cell{}
+	copies := make(map[*Piece]*Piece, 32)
+	for x := range b.board {
+		for y := range b.board[x] {
+			if piece := b.board[x][y].piece; piece != nil {
+				copied := *piece
+				copied.targetedCells = append([]Position(nil), piece.targetedCells...)
+				copies[piece] = &copied
+				clone.board[x][y].piece = &copied
+			}
+		}
+	}
+	for x := range b.board {
+		for y := range b.board[x] {
+			for _, piece := range b.board[x][y].targetedBy {
+				clone.board[x][y].targetedBy = append(clone.board[x][y].targetedBy, copies[piece])
+			}
+		}
+	}
+	return clone
+}
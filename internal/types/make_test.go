@@ -0,0 +1,157 @@
+package types_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"ust_chess/internal/types"
+)
+
+var fens = []string{
+	types.StartFEN,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"4k3/8/8/3pP3/8/8/8/4K3 w - d6",
+}
+
+func parse(t *testing.T, fen string) types.Board {
+	t.Helper()
+	b, err := types.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// attackMap lists attackers of every cell by where they stand, e.g.
+// "e4:d3,f3".
+func attackMap(b *types.Board) string {
+	out := ""
+	for x := range 8 {
+		for y := range 8 {
+			var attackers []string
+			for _, piece := range b.GetCell(types.MustNewPos(x, y)).GetTargetedBy() {
+				attackers = append(attackers, piece.GetPosition().Notation())
+			}
+			slices.Sort(attackers)
+			out += fmt.Sprintf(" %s:%s", types.MustNewPos(x, y).Notation(), strings.Join(attackers, ","))
+		}
+	}
+	return out
+}
+
+// snapshot lists the pieces by cell with their ids, e.g. "e4:P12", the
+// bitboards, the attacks and the FEN, which has the side to move and its
+// rights.
+func snapshot(b *types.Board) string {
+	out := fmt.Sprint(b.Bitboards())
+	for x := range 8 {
+		for y := range 8 {
+			if piece := b.GetCell(types.MustNewPos(x, y)).GetPiece(); piece != nil {
+				out += fmt.Sprintf(" %s:%s%v%d", piece.GetPosition().Notation(), piece.GetType(), piece.IsWhite(), piece.GetId())
+			}
+		}
+	}
+	return out + attackMap(b) + " " + b.FEN()
+}
+
+// TestMakeUnmake plays random moves with Make, checks every one against a
+// board set up anew and takes them all back one by one.
+func TestMakeUnmake(t *testing.T) {
+	random := rand.New(rand.NewPCG(3, 4))
+	for _, fen := range fens {
+		for range 20 {
+			b := parse(t, fen)
+			var undos []types.Undo
+			var before []string
+			for range 60 {
+				moves := b.LegalMoves()
+				if len(moves) == 0 {
+					break
+				}
+				move := moves[random.IntN(len(moves))]
+				before = append(before, snapshot(&b))
+
+				// Taken back at once, the move leaves no trace.
+				b.Unmake(b.Make(move))
+				if got := snapshot(&b); got != before[len(before)-1] {
+					t.Fatalf("%s, %s taken back:\n%s\nwant\n%s", b.FEN(), move, got, before[len(before)-1])
+				}
+
+				undos = append(undos, b.Make(move))
+				fresh := parse(t, b.FEN())
+				if got, want := attackMap(&b), attackMap(&fresh); got != want {
+					t.Fatalf("%s after %s: attacks\n%s\nwant\n%s", b.FEN(), move, got, want)
+				}
+			}
+			for i := len(undos) - 1; i >= 0; i-- {
+				b.Unmake(undos[i])
+				if got := snapshot(&b); got != before[i] {
+					t.Fatalf("%s: unmake %d:\n%s\nwant\n%s", fen, i, got, before[i])
+				}
+			}
+		}
+	}
+}
+
+// TestClone moves pieces on clones and checks that the board they were
+// made from does not change.
+func TestClone(t *testing.T) {
+	random := rand.New(rand.NewPCG(5, 6))
+	b := parse(t, fens[1])
+	for range 40 {
+		moves := b.LegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		want := snapshot(&b)
+		clone := b.Clone()
+		if got := snapshot(&clone); got != want {
+			t.Fatalf("clone:\n%s\nwant\n%s", got, want)
+		}
+		for range 10 {
+			moves := clone.LegalMoves()
+			if len(moves) == 0 {
+				break
+			}
+			clone.Make(moves[random.IntN(len(moves))])
+		}
+		if got := snapshot(&b); got != want {
+			t.Fatalf("%s: original changed by the clone:\n%s\nwant\n%s", b.FEN(), got, want)
+		}
+		b.Make(moves[random.IntN(len(moves))])
+	}
+}
+
+// TestMakeRights checks what a move leaves of the rights of both sides.
+func TestMakeRights(t *testing.T) {
+	tests := []struct {
+		fen, move, want string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 5 1", "e1g1", "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 6 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "2kr3r/8/8/8/8/8/8/R3K2R w KQ - 1 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 3 1", "a1a8", "R3k2r/8/8/8/8/8/8/4K2R b Kk - 0 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 3 1", "h1h2", "r3k2r/8/8/8/8/8/7R/R3K3 b Qkq - 4 1"},
+		{"4k3/8/8/8/4p3/8/3P4/4K3 w - - 7 1", "d2d4", "4k3/8/8/8/3Pp3/8/8/4K3 b - d3 0 1"},
+		{"4k3/8/8/8/3Pp3/8/8/4K3 b - d3 0 1", "e4d3", "4k3/8/8/8/8/3p4/8/4K3 w - - 0 1"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 9 1", "b7b8n", "1N2k3/8/8/8/8/8/8/4K3 b - - 0 1"},
+	}
+	for _, tt := range tests {
+		b := parse(t, tt.fen)
+		move, err := types.ParseMove(tt.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move, err = b.Legal(move); err != nil {
+			t.Fatalf("%s: %s: %v", tt.fen, tt.move, err)
+		}
+		b.Make(move)
+		if got := b.FEN(); got != tt.want {
+			t.Errorf("%s after %s: %s, want %s", tt.fen, tt.move, got, tt.want)
+		}
+	}
+}
@@ -23,6 +23,7 @@ type Move struct {
 	posInit   Position
 	posFinal  Position
 	Direction Direction
+	Promotion Figure // for a pawn reaching the last rank, zero for a queen
 }
 
 var (
@@ -42,7 +43,7 @@ func GetMove(ix, iy, fx, fy int) (Move, error) {
 		return Move{}, errors.Join(ErrFinalPos, err)
 	}
 
-	var move = Move{posInit, posFinal, getMoveDirection(ix, iy, fx, fy)}
+	var move = Move{posInit: posInit, posFinal: posFinal, Direction: getMoveDirection(ix, iy, fx, fy)}
 
 	if move.GetDirection() == SAME_SQUARE {
 		return Move{},
@@ -55,6 +56,64 @@ func (m Move) String() string {
 	return fmt.Sprintf("%s->%s", m.GetInitial(), m.GetFinal())
 }
 
+// Notation of the move in long algebraic form, e.g. "e2e4" or "e7e8q"
+// with a promotion.
+func (m Move) Notation() string {
+	notation := m.GetInitial().Notation() + m.GetFinal().Notation()
+	if m.Promotion != 0 {
+		notation += string(promotionLetters[m.Promotion])
+	}
+	return notation
+}
+
+var promotionLetters = map[Figure]byte{QUEEN: 'q', ROOK: 'r', BISHOP: 'b', KNIGHT: 'n'}
+
+// ParseMove reads a move in long algebraic form, e.g. "e2e4" or "e7e8q".
+func ParseMove(notation string) (Move, error) {
+	var promotion Figure
+	if len(notation) == 5 {
+		for figure, letter := range promotionLetters {
+			if notation[4] == letter {
+				promotion = figure
+			}
+		}
+		if promotion == 0 {
+			return Move{}, errors.Join(ErrBadNotation, errors.New(notation))
+		}
+		notation = notation[:4]
+	}
+	if len(notation) != 4 {
+		return Move{}, errors.Join(ErrBadNotation, errors.New(notation))
+	}
+	initial, err := ParsePos(notation[:2])
+	if err != nil {
+		return Move{}, errors.Join(ErrInitialPos, err)
+	}
+	final, err := ParsePos(notation[2:])
+	if err != nil {
+		return Move{}, errors.Join(ErrFinalPos, err)
+	}
+	move, err := GetMove(initial.GetX(), initial.GetY(), final.GetX(), final.GetY())
+	if err != nil {
+		return Move{}, err
+	}
+	move.Promotion = promotion
+	return move, nil
+}
+
+func (m Move) MarshalText() ([]byte, error) {
+	return []byte(m.Notation()), nil
+}
+
+func (m *Move) UnmarshalText(text []byte) error {
+	move, err := ParseMove(string(text))
+	if err != nil {
+		return err
+	}
+	*m = move
+	return nil
+}
+
 func (m Move) GetInitial() Position {
 	return m.posInit
 }
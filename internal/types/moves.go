@@ -0,0 +1,155 @@
+package types
+
+import "errors"
+
+var (
+	ErrIllegalMove = errors.New("illegal move")
+	ErrKingInCheck = errors.New("king would be in check")
+)
+
+// PseudoLegalMoves of the side to move: moves by the rules of the pieces
+// that may leave the own king in check. Castlings are only the safe ones.
+func (b *Board) PseudoLegalMoves() []Move {
+	moves := make([]Move, 0, 48)
+	white := !b.isBlackTurn
+	add := func(from, to int) {
+		move := MoveOf(from, to)
+		if b.bitboards.Of(PAWN, white).Has(from) && (to/8 == 0 || to/8 == 7) {
+			for _, figure := range []Figure{QUEEN, ROOK, BISHOP, KNIGHT} {
+				move.Promotion = figure
+				moves = append(moves, move)
+			}
+			return
+		}
+		moves = append(moves, move)
+	}
+	addAll := func(from int, targets Bitboard) {
+		for targets != 0 {
+			add(from, targets.Pop())
+		}
+	}
+	own, enemy := b.bitboards.Color(white), b.bitboards.Color(!white)
+	occupied := own | enemy
+
+	forward, start := 8, 1
+	if !white {
+		forward, start = -8, 6
+	}
+	// A pawn that just made a double step is taken on the cell it passed.
+	takes := enemy
+	if x, y := b.enPassant, 5; x >= 0 {
+		if !white {
+			y = 2
+		}
+		takes |= 1 << (y*8 + x)
+	}
+	for pawns := b.bitboards.Of(PAWN, white); pawns != 0; {
+		from := pawns.Pop()
+		if to := from + forward; !occupied.Has(to) {
+			add(from, to)
+			if from/8 == start && !occupied.Has(to+forward) {
+				add(from, to+forward)
+			}
+		}
+		addAll(from, PawnAttacks(white, from)&takes)
+	}
+	for _, figure := range []Figure{KNIGHT, BISHOP, ROOK, QUEEN, KING} {
+		for pieces := b.bitboards.Of(figure, white); pieces != 0; {
+			from := pieces.Pop()
+			addAll(from, Attacks(figure, white, from, occupied)&^own)
+			if figure == KING {
+				for _, to := range b.castlings(PosOf(from)) {
+					add(from, to.Square())
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// castlings are the cells the king on the cell may castle to now.
+func (b *Board) castlings(king Position) []Position {
+	white := !b.isBlackTurn
+	rank, kingside, queenside := 0, WHITE_KINGSIDE, WHITE_QUEENSIDE
+	if !white {
+		rank, kingside, queenside = 7, BLACK_KINGSIDE, BLACK_QUEENSIDE
+	}
+	if king != (Position{kingFile, rank}) || b.Attacked(king, !white) {
+		return nil
+	}
+	empty := func(files ...int) bool {
+		for _, file := range files {
+			if b.board[file][rank].piece != nil {
+				return false
+			}
+		}
+		return true
+	}
+	safe := func(files ...int) bool {
+		for _, file := range files {
+			if b.Attacked(Position{file, rank}, !white) {
+				return false
+			}
+		}
+		return true
+	}
+	var cells []Position
+	rook := func(file int) bool {
+		piece := b.board[file][rank].piece
+		return piece != nil && piece.figure == ROOK && piece.isWhite == white
+	}
+	if b.castling&kingside != 0 && rook(kingsideRook) && empty(1, 2) && safe(1, 2) {
+		cells = append(cells, Position{1, rank})
+	}
+	if b.castling&queenside != 0 && rook(queensideRook) && empty(4, 5, 6) && safe(4, 5) {
+		cells = append(cells, Position{5, rank})
+	}
+	return cells
+}
+
+// LegalMoves of the side to move.
+func (b *Board) LegalMoves() []Move {
+	moves := b.PseudoLegalMoves()
+	legal := moves[:0]
+	for _, move := range moves {
+		if b.safe(move) {
+			legal = append(legal, move)
+		}
+	}
+	return legal
+}
+
+// safe tells if the pseudo-legal move leaves the own king out of check.
+func (b *Board) safe(move Move) bool {
+	initial, final := move.GetInitial(), move.GetFinal()
+	piece := b.GetCell(initial).piece
+	switch {
+	case piece.figure == KING && iAbs(final.x-initial.x) == 2:
+		return true
+	case piece.figure == PAWN && initial.x != final.x && b.GetCell(final).piece == nil:
+		undo := b.Make(move)
+		check := b.InCheck(piece.isWhite)
+		b.Unmake(undo)
+		return !check
+	}
+	return b.KeepsKingSafe(move)
+}
+
+// Legal finds the move among the legal ones. A pawn reaching the last rank
+// becomes a queen unless the move says otherwise.
+func (b *Board) Legal(move Move) (Move, error) {
+	if piece := b.GetCell(move.GetInitial()).piece; piece != nil && piece.figure == PAWN &&
+		(move.GetFinal().y == 0 || move.GetFinal().y == 7) && move.Promotion == 0 {
+		move.Promotion = QUEEN
+	}
+	for _, pseudo := range b.PseudoLegalMoves() {
+		if pseudo != move {
+			continue
+		}
+		if !b.safe(move) {
+			return move, errors.Join(ErrIllegalMove, ErrKingInCheck)
+		}
+		return move, nil
+	}
+	return move, ErrIllegalMove
+}
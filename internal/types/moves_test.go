@@ -0,0 +1,48 @@
+package types_test
+
+import (
+	"testing"
+	"ust_chess/internal/types"
+)
+
+func perft(b *types.Board, depth int) int {
+	moves := b.LegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, move := range moves {
+		undo := b.Make(move)
+		nodes += perft(b, depth-1)
+		b.Unmake(undo)
+	}
+	return nodes
+}
+
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		nodes []int
+	}{
+		{"start", types.StartFEN, []int{20, 400, 8902}},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -", []int{48, 2039, 97862}},
+		{"en passant and pins", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -", []int{14, 191, 2812, 43238}},
+		{"promotions", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467}},
+		{"promotions mirrored", "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1", []int{6, 264, 9467}},
+		{"checks", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := parse(t, tt.fen)
+			for depth, want := range tt.nodes {
+				if testing.Short() && depth > 1 {
+					break
+				}
+				if got := perft(&b, depth+1); got != want {
+					t.Errorf("depth %d: %d nodes, want %d", depth+1, got, want)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 )
 
 type IPiece interface {
@@ -19,6 +20,8 @@ type IPiece interface {
 	String() string
 }
 
+var _ IPiece = (*Piece)(nil)
+
 type Figure rune
 
 const (
@@ -55,6 +58,25 @@ func (t Figure) String() string {
 	return string(t)
 }
 
+// Value of the figure in centipawns, for the evaluator, the engine and
+// exchanges alike. Kings are not counted.
+func (f Figure) Value() int {
+	switch f {
+	case QUEEN:
+		return 900
+	case ROOK:
+		return 500
+	case BISHOP:
+		return 330
+	case KNIGHT:
+		return 320
+	case PAWN:
+		return 100
+	default:
+		return 0
+	}
+}
+
 var (
 	ErrFigureNotSupported = errors.New("figure not in provided constants")   // Rare ocasion during board build or piece transformation
 	ErrSameColorPiece     = errors.New("can't take own piece")               // Maybe should wrap piece position
@@ -80,22 +102,21 @@ func (p *Piece) Take() {
 	p.isTaken = true
 }
 
-// ID generation
-var sequence = 0
+// ID generation, pieces are made by many games at once.
+var sequence atomic.Int64
 
 func NewPiece(pieceType Figure, isWhite bool, position Position) (Piece, error) {
 	if pieceType < KING || pieceType >= CONST_FIGURE_LIST_LENGTH {
 		return Piece{}, errors.Join(ErrFigureNotSupported, fmt.Errorf("%s", pieceType))
 	}
-	sequence++
 	return Piece{
 		figure:        pieceType,
-		id:            sequence,
+		id:            int(sequence.Add(1)),
 		position:      position,
 		isTaken:       false,
 		isWhite:       isWhite,
 		score:         0,
-		targetedCells: make([]Position, 2),
+		targetedCells: nil,
 	}, nil
 }
 
@@ -155,6 +176,22 @@ func (p *Piece) ClearTargetedCells(board *Board) {
 	for _, position := range p.targetedCells {
 		board.GetCell(position).RemoveFromTargetedBy(p.GetId())
 	}
+	p.targetedCells = p.targetedCells[:0]
+}
+
+// MarkTargetedCells notes the cells the piece attacks, on the piece and on
+// the cells, in place of what was noted before. Pawns attack diagonally
+// forward whether or not there is anything to take there, sliding pieces
+// stop at the first piece on the way, own or not.
+func (p *Piece) MarkTargetedCells(board *Board) {
+	p.ClearTargetedCells(board)
+	attacks := Attacks(p.figure, p.isWhite, p.position.Square(), board.bitboards.Occupied())
+	for attacks != 0 {
+		pos := PosOf(attacks.Pop())
+		p.targetedCells = append(p.targetedCells, pos)
+		cell := board.GetCell(pos)
+		cell.targetedBy = append(cell.targetedBy, p)
+	}
 }
 
 func (p *Piece) MakeMove(move Move, board *Board) error {
@@ -228,56 +265,54 @@ func checkMoveQueen(move Move, board *Board) error {
 
 func checkMovePawn(move Move, board *Board) error {
 	piece := board.GetCell(move.GetInitial()).GetPiece()
-	ix := move.GetInitial().GetX()
-	iy := move.GetInitial().GetY()
-	fy := move.GetFinal().GetY()
-	fx := move.GetFinal().GetX()
-	dx := iAbs(ix - fx)
-	dy := iAbs(iy - fy)
-
-	if dy > 2 || dx > 1 || dx == 1 && dy != 1 {
-		return ErrWrongMovePattern
+	target := board.GetCell(move.GetFinal()).GetPiece()
+	ix, iy := move.GetInitial().GetX(), move.GetInitial().GetY()
+	forward, start := 1, 1
+	if !piece.IsWhite() {
+		forward, start = -1, 6
 	}
-	if dy == 2 {
-		if piece.IsWhite() && iy != 1 || !piece.IsWhite() && iy != 6 {
+	dx := iAbs(move.GetFinal().GetX() - ix)
+	dy := move.GetFinal().GetY() - iy
+
+	switch {
+	case dx == 0 && dy == forward:
+		if target != nil {
 			return ErrMoveNotPossibleNow
 		}
-		if move.GetDirection() == UP {
-			pos := MustNewPos(ix, iy+1)
-			if board.GetCell(pos).GetPiece() != nil {
-				return errors.Join(ErrCantJumpOverPieces, fmt.Errorf("%s", pos))
-			}
-		} else {
-			pos := MustNewPos(ix, iy-1)
-			if board.GetCell(pos).GetPiece() != nil {
-				errors.Join(ErrCantJumpOverPieces, fmt.Errorf("%s", pos))
-			}
+	case dx == 0 && dy == 2*forward:
+		if iy != start || target != nil {
+			return ErrMoveNotPossibleNow
 		}
-		left, err := NewPos(ix-1, fy)
-		if err == nil {
-			if l_piece := board.GetCell(left).GetPiece(); l_piece != nil {
-				return ErrEnPassantMove
-			}
+		pos := MustNewPos(ix, iy+forward)
+		if board.GetCell(pos).GetPiece() != nil {
+			return errors.Join(ErrCantJumpOverPieces, fmt.Errorf("%s", pos))
 		}
-		right, err := NewPos(ix-1, fy)
-		if err == nil {
-			if r_piece := board.GetCell(right).GetPiece(); r_piece != nil {
-				return ErrEnPassantMove
-			}
+	case dx == 1 && dy == forward:
+		if target == nil {
+			return ErrEnPassantTake
 		}
-	} else if dx == 1 && board.GetCell(move.GetFinal()).GetPiece() == nil && board.GetCell(MustNewPos(iy, iy)).GetPiece() != nil && (piece.IsWhite() && board.GetCell(MustNewPos(ix, iy-1)).GetPiece() != nil || board.GetCell(MustNewPos(ix, iy+1)).GetPiece() != nil) {
-		return ErrEnPassantTake
-	} else {
-		front := board.GetCell(move.GetFinal()).GetPiece()
-		if front != nil {
-			return ErrMoveNotPossibleNow
+		if target.IsWhite() == piece.IsWhite() {
+			return ErrSameColorPiece
 		}
+	default:
+		return ErrWrongMovePattern
 	}
 	return nil
 }
 
 func checkMoveKing(move Move, board *Board) error {
-	return nil
+	dx := iAbs(move.GetFinal().GetX() - move.GetInitial().GetX())
+	dy := iAbs(move.GetFinal().GetY() - move.GetInitial().GetY())
+	switch {
+	case dx <= 1 && dy <= 1:
+		if !checkSameColorTake(move, board) {
+			return ErrSameColorPiece
+		}
+		return nil
+	case dx == 2 && dy == 0:
+		return ErrCastleMove
+	}
+	return errors.Join(ErrWrongMovePattern, fmt.Errorf("%s", move))
 }
 
 func checkMovePatternStraight(move Move) bool {
@@ -297,93 +332,17 @@ func checkMovePatternKnight(move Move) bool {
 }
 
 func checkJumpOverPieceStraightOrDiagonal(move Move, board *Board) error {
-	ix := move.GetInitial().GetX()
-	fx := move.GetFinal().GetX()
-	iy := move.GetInitial().GetY()
-	fy := move.GetFinal().GetY()
-	switch move.GetDirection() {
-	case UP:
-		iy++
-		for iy < fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			iy++
-		}
-	case DOWN:
-		iy--
-		for iy > fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			iy--
-		}
-	case LEFT:
-		ix--
-		for ix > fx {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix--
-		}
-	case RIGHT:
-		ix++
-		for ix < fx {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix++
-		}
-	case LEFT_UP:
-		ix--
-		iy++
-		for ix > fx && iy < fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix--
-			iy++
-		}
-	case LEFT_DOWN:
-		ix--
-		iy--
-		for ix > fx && iy > fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix--
-			iy--
-		}
-	case RIGHT_UP:
-		ix++
-		iy++
-		for ix < fx && iy < fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix++
-			iy++
-		}
-	case RIGHT_DOWN:
-		ix++
-		iy--
-		for ix < fx && iy > fy {
-			pos := MustNewPos(ix, iy)
-			if piece := board.GetCell(pos).GetPiece(); piece != nil {
-				return fmt.Errorf("%s", pos)
-			}
-			ix++
-			iy--
-		}
+	direction := move.GetDirection()
+	if direction == SAME_SQUARE {
+		return nil
+	}
+	// The ray from the initial cell without the final one and what lies
+	// beyond it.
+	final := move.GetFinal().Square()
+	between := rays[direction][move.GetInitial().Square()] &^ rays[direction][final] &^ (1 << final)
+	if obstacles := between & board.bitboards.Occupied(); obstacles != 0 {
+		return fmt.Errorf("%s", PosOf(nearest(direction, obstacles)))
 	}
-
 	return nil
 }
 
@@ -13,7 +13,7 @@ type Position struct {
 var ErrOutOfBounds = errors.New("out of bounds position")
 
 func NewPos(x, y int) (Position, error) {
-	if x < 0 || x > 8 {
+	if x < 0 || x > 7 || y < 0 || y > 7 {
 		return Position{},
 			errors.Join(ErrOutOfBounds, errors.New(Position{x, y}.String()))
 	}
@@ -32,6 +32,26 @@ func (p Position) String() string {
 	return fmt.Sprintf("(%d; %d)", p.GetX(), p.GetY())
 }
 
+// Notation of the cell in algebraic form. White king starts at x=3 which
+// is the "e" file, so files go from "h" at x=0 to "a" at x=7.
+func (p Position) Notation() string {
+	return fmt.Sprintf("%c%d", 'h'-p.GetX(), p.GetY()+1)
+}
+
+var ErrBadNotation = errors.New("bad square notation")
+
+// ParsePos reads a cell in algebraic notation like "e2".
+func ParsePos(notation string) (Position, error) {
+	if len(notation) != 2 {
+		return Position{}, errors.Join(ErrBadNotation, errors.New(notation))
+	}
+	file, rank := notation[0], notation[1]
+	if file < 'a' || file > 'h' || rank < '1' || rank > '8' {
+		return Position{}, errors.Join(ErrBadNotation, errors.New(notation))
+	}
+	return NewPos(int('h'-file), int(rank-'1'))
+}
+
 func (p Position) GetX() int {
 	return p.x
 }
@@ -39,3 +59,16 @@ func (p Position) GetX() int {
 func (p Position) GetY() int {
 	return p.y
 }
+
+func (p Position) MarshalText() ([]byte, error) {
+	return []byte(p.Notation()), nil
+}
+
+func (p *Position) UnmarshalText(text []byte) error {
+	pos, err := ParsePos(string(text))
+	if err != nil {
+		return err
+	}
+	*p = pos
+	return nil
+}
@@ -0,0 +1,136 @@
+package types
+
+// kingSEEValue makes a king the last piece to take with: it can't be taken
+// back.
+const kingSEEValue = 10000
+
+func seeValue(f Figure) int {
+	if f == KING {
+		return kingSEEValue
+	}
+	return f.Value()
+}
+
+// SEE (static exchange evaluation) is the material the side making the
+// move wins on its final cell once every capture there is played out, the
+// least valuable attacker first, each side free to stop taking. Negative
+// when the move loses material. The move should be legal, pins are not
+// looked at.
+func (b *Board) SEE(move Move) int {
+	target := move.GetFinal()
+	mover := b.GetCell(move.GetInitial()).GetPiece()
+	if mover == nil {
+		return 0
+	}
+
+	gain := []int{0}
+	if captured := b.GetCell(target).GetPiece(); captured != nil {
+		gain[0] = captured.GetType().Value()
+	} else if mover.GetType() == PAWN && move.GetInitial().GetX() != target.GetX() {
+		gain[0] = PAWN.Value() // en passant
+	}
+	onCell := seeValue(mover.GetType())
+	if move.Promotion != 0 {
+		gain[0] += move.Promotion.Value() - PAWN.Value()
+		onCell = move.Promotion.Value()
+	}
+
+	used := map[*Piece]bool{mover: true}
+	attackers := append([]*Piece{}, b.GetCell(target).GetTargetedBy()...)
+	attackers = append(attackers, b.xray(target, move.GetInitial(), used)...)
+	white := !mover.IsWhite()
+	for {
+		next := leastValuable(attackers, used, white)
+		if next == nil {
+			break
+		}
+		// what the side gets if it takes, given what it lost so far
+		gain = append(gain, onCell-gain[len(gain)-1])
+		used[next] = true
+		attackers = append(attackers, b.xray(target, next.GetPosition(), used)...)
+		onCell = seeValue(next.GetType())
+		white = !white
+	}
+	// Going back, each side takes only if it is better than stopping.
+	for d := len(gain) - 1; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
+
+func leastValuable(attackers []*Piece, used map[*Piece]bool, white bool) *Piece {
+	var least *Piece
+	for _, attacker := range attackers {
+		if used[attacker] || attacker.IsWhite() != white {
+			continue
+		}
+		if least == nil || seeValue(attacker.GetType()) < seeValue(least.GetType()) {
+			least = attacker
+		}
+	}
+	return least
+}
+
+// xray finds the sliding piece behind the one that leaves from, on the
+// line to the target, that attacks the target once the way is free.
+func (b *Board) xray(target, from Position, used map[*Piece]bool) []*Piece {
+	dx, dy := sign(from.GetX()-target.GetX()), sign(from.GetY()-target.GetY())
+	straight := dx == 0 || dy == 0
+	if !straight && iAbs(from.GetX()-target.GetX()) != iAbs(from.GetY()-target.GetY()) {
+		return nil // knights don't hide anything
+	}
+	for x, y := from.GetX()+dx, from.GetY()+dy; ; x, y = x+dx, y+dy {
+		pos, err := NewPos(x, y)
+		if err != nil {
+			return nil
+		}
+		piece := b.GetCell(pos).GetPiece()
+		switch {
+		case piece == nil:
+			continue
+		case used[piece]:
+			continue
+		case piece.GetType() == QUEEN,
+			straight && piece.GetType() == ROOK,
+			!straight && piece.GetType() == BISHOP:
+			return []*Piece{piece}
+		}
+		return nil
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// HangingPieces of the color: pieces the opponent wins material on by
+// taking them. Kings are left out, an attacked king is a check.
+func (b *Board) HangingPieces(white bool) []*Piece {
+	var hanging []*Piece
+	for x := range b.board {
+		for y := range b.board[x] {
+			cell := &b.board[x][y]
+			piece := cell.GetPiece()
+			if piece == nil || piece.IsWhite() != white || piece.GetType() == KING {
+				continue
+			}
+			for _, attacker := range cell.GetTargetedBy() {
+				if attacker.IsWhite() == white {
+					continue
+				}
+				move, err := GetMove(attacker.GetPosition().GetX(), attacker.GetPosition().GetY(), x, y)
+				if err == nil && b.SEE(move) > 0 {
+					hanging = append(hanging, piece)
+					break
+				}
+			}
+		}
+	}
+	return hanging
+}
@@ -0,0 +1,408 @@
+// Package uci drives chess engines that speak the Universal Chess
+// Interface, e.g. Stockfish, as subprocesses.
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+)
+
+var (
+	ErrEngineExited = errors.New("engine exited")
+	ErrTimeout      = errors.New("engine didn't answer in time")
+	ErrBadOutput    = errors.New("engine said something wrong")
+)
+
+// DefaultTimeout for answers that should come at once, e.g. "readyok" or
+// "bestmove" after "stop".
+const DefaultTimeout = 5 * time.Second
+
+// Limits of a search, zero values are not sent. Without any the search
+// goes on until the context is done.
+type Limits struct {
+	Depth     int
+	Nodes     int
+	MoveTime  time.Duration
+	WhiteTime time.Duration
+	BlackTime time.Duration
+	WhiteInc  time.Duration
+	BlackInc  time.Duration
+}
+
+// Info is a report of the engine during a search. Score is in centipawns
+// for the side to move, Mate in moves to mate, negative when the side is
+// mated, zero if no mate is seen.
+type Info struct {
+	Depth int
+	Nodes int
+	Time  time.Duration
+	Score int
+	Mate  int
+	PV    []types.Move
+}
+
+// Result of a search: the move and the last report before it.
+type Result struct {
+	Move   types.Move
+	Ponder *types.Move
+	Info   Info
+}
+
+// Engine is a running engine process. Commands are sent one at a time.
+type Engine struct {
+	Name    string        // from "id name"
+	Timeout time.Duration // for answers that should come at once
+
+	mu       sync.Mutex // one exchange at a time
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan string   // output of the engine, closed when it exits
+	exited   chan struct{} // closed when the process is gone
+	err      error         // why it exited
+	position string        // last position command sent
+	history  []types.Move  // moves of the last position, for ucinewgame
+	pos      types.Board
+}
+
+// Start runs the engine and waits for it to get ready, for Timeout or until
+// the context is done.
+func Start(ctx context.Context, path string, args ...string) (*Engine, error) {
+	e := &Engine{
+		Timeout: DefaultTimeout,
+		cmd:     exec.Command(path, args...),
+		lines:   make(chan string, 64),
+		exited:  make(chan struct{}),
+	}
+	var err error
+	if e.stdin, err = e.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := e.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go e.read(stdout)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.send("uci"); err != nil {
+		e.kill()
+		return nil, err
+	}
+	err = e.expect(ctx, "uciok", func(line string) {
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			e.Name = name
+		}
+	})
+	if err == nil {
+		err = e.ready(ctx)
+	}
+	if err != nil {
+		e.kill()
+		return nil, err
+	}
+	e.pos, _ = types.ParseFEN(types.StartFEN)
+	return e, nil
+}
+
+// read passes output lines on until the engine exits.
+func (e *Engine) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		e.lines <- scanner.Text()
+	}
+	e.err = e.cmd.Wait()
+	close(e.lines)
+	close(e.exited)
+}
+
+// Sync sends the position of the game. Games are sent as moves from the
+// start, so the engine knows about repetitions, unless they started from
+// another position. Nothing is sent if the position didn't change.
+func (e *Engine) Sync(game *board.Game) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	moves := make([]types.Move, len(game.History))
+	for i, record := range game.History {
+		moves[i] = record.Move
+	}
+	// The moves don't lead to the game if it started elsewhere.
+	pos, _ := types.ParseFEN(types.StartFEN)
+	fromStart := true
+	notations := make([]string, len(moves))
+	for i, move := range moves {
+		if _, err := pos.Legal(move); err != nil {
+			fromStart = false
+		}
+		if fromStart {
+			pos.Make(move)
+		}
+		notations[i] = move.Notation()
+	}
+	command := "position startpos"
+	if len(moves) > 0 {
+		command += " moves " + strings.Join(notations, " ")
+	}
+	if !fromStart || pos.FEN() != game.Board.FEN() {
+		command, moves = "position fen "+game.Board.FEN(), nil
+	}
+	if command == e.position {
+		return nil
+	}
+
+	// A new game unless this one goes on from the last position.
+	if !continues(e.history, moves) || len(moves) == 0 {
+		if err := e.send("ucinewgame"); err != nil {
+			return err
+		}
+	}
+	if err := e.send(command); err != nil {
+		return err
+	}
+	if err := e.ready(context.Background()); err != nil {
+		return err
+	}
+	e.position, e.history, e.pos = command, moves, game.Board.Clone()
+	return nil
+}
+
+func continues(before, after []types.Move) bool {
+	return len(before) > 0 && len(after) >= len(before) && slices.Equal(before, after[:len(before)])
+}
+
+// Go searches the synced position within the limits. Reports go to
+// onInfo if it is set. When the context is done the search is stopped and
+// its move is returned, an engine that doesn't answer "stop" in Timeout is
+// killed.
+func (e *Engine) Go(ctx context.Context, limits Limits, onInfo func(Info)) (Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.send(goCommand(limits)); err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	stopped := false
+	done := ctx.Done()
+	var timeout <-chan time.Time
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return result, e.exitErr()
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "info":
+				if info, ok := parseInfo(fields[1:]); ok {
+					result.Info = info
+					if onInfo != nil {
+						onInfo(info)
+					}
+				}
+			case "bestmove":
+				return e.bestMove(fields[1:], result)
+			}
+		case <-done:
+			if stopped {
+				continue
+			}
+			stopped, done = true, nil
+			if err := e.send("stop"); err != nil {
+				return result, err
+			}
+			timeout = time.After(e.Timeout)
+		case <-timeout:
+			e.kill()
+			return result, errors.Join(ErrTimeout, errors.New("bestmove"))
+		}
+	}
+}
+
+func (e *Engine) bestMove(fields []string, result Result) (Result, error) {
+	if len(fields) == 0 || fields[0] == "0000" || fields[0] == "(none)" {
+		return result, errors.Join(ErrBadOutput, errors.New("no move"))
+	}
+	move, err := types.ParseMove(fields[0])
+	if err != nil {
+		return result, errors.Join(ErrBadOutput, err)
+	}
+	if result.Move, err = e.pos.Legal(move); err != nil {
+		return result, errors.Join(ErrBadOutput, fmt.Errorf("bestmove %s", fields[0]), err)
+	}
+	if len(fields) == 3 && fields[1] == "ponder" {
+		if ponder, err := types.ParseMove(fields[2]); err == nil {
+			result.Ponder = &ponder
+		}
+	}
+	return result, nil
+}
+
+func goCommand(limits Limits) string {
+	command := "go"
+	add := func(name string, value int64) {
+		if value > 0 {
+			command += " " + name + " " + strconv.FormatInt(value, 10)
+		}
+	}
+	add("depth", int64(limits.Depth))
+	add("nodes", int64(limits.Nodes))
+	add("movetime", limits.MoveTime.Milliseconds())
+	add("wtime", limits.WhiteTime.Milliseconds())
+	add("btime", limits.BlackTime.Milliseconds())
+	add("winc", limits.WhiteInc.Milliseconds())
+	add("binc", limits.BlackInc.Milliseconds())
+	if command == "go" {
+		command += " infinite"
+	}
+	return command
+}
+
+// parseInfo reads the fields of an "info" line. Lines without a score,
+// e.g. "info string ...", are skipped.
+func parseInfo(fields []string) (Info, bool) {
+	var info Info
+	scored := false
+	for i := 0; i < len(fields); i++ {
+		value := func() int {
+			if i+1 >= len(fields) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(fields[i])
+			return n
+		}
+		switch fields[i] {
+		case "string":
+			return Info{}, false
+		case "depth":
+			info.Depth = value()
+		case "nodes":
+			info.Nodes = value()
+		case "time":
+			info.Time = time.Duration(value()) * time.Millisecond
+		case "score":
+			if i+1 < len(fields) {
+				i++
+				switch fields[i] {
+				case "cp":
+					info.Score, scored = value(), true
+				case "mate":
+					info.Mate, scored = value(), true
+				}
+			}
+		case "pv":
+			for _, notation := range fields[i+1:] {
+				move, err := types.ParseMove(notation)
+				if err != nil {
+					break
+				}
+				info.PV = append(info.PV, move)
+			}
+			i = len(fields)
+		}
+	}
+	return info, scored
+}
+
+// Close asks the engine to quit and kills it if it doesn't. An engine that
+// can't be asked is gone already, it tells why.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, sendErr := io.WriteString(e.stdin, "quit\n")
+	select {
+	case <-e.exited:
+	case <-time.After(e.Timeout):
+		e.kill()
+		return errors.Join(ErrTimeout, sendErr)
+	}
+	if sendErr != nil {
+		return errors.Join(ErrEngineExited, sendErr, e.err)
+	}
+	return nil
+}
+
+// ready waits for the engine to handle the commands sent so far. Caller
+// holds the lock.
+func (e *Engine) ready(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.expect(ctx, "readyok", nil)
+}
+
+// expect skips output until the line, passing the skipped lines to seen.
+// Caller holds the lock.
+func (e *Engine) expect(ctx context.Context, want string, seen func(string)) error {
+	timeout := time.After(e.Timeout)
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return e.exitErr()
+			}
+			if strings.TrimSpace(line) == want {
+				return nil
+			}
+			if seen != nil {
+				seen(line)
+			}
+		case <-timeout:
+			e.kill()
+			return errors.Join(ErrTimeout, errors.New(want))
+		case <-ctx.Done():
+			e.kill()
+			return errors.Join(ErrTimeout, errors.New(want), ctx.Err())
+		}
+	}
+}
+
+// send writes the command. Caller holds the lock.
+func (e *Engine) send(command string) error {
+	select {
+	case <-e.exited:
+		return e.exitErr()
+	default:
+	}
+	if _, err := io.WriteString(e.stdin, command+"\n"); err != nil {
+		return errors.Join(ErrEngineExited, err)
+	}
+	return nil
+}
+
+// exitErr waits for the process to be gone and tells why it exited.
+func (e *Engine) exitErr() error {
+	<-e.exited
+	if e.err != nil {
+		return errors.Join(ErrEngineExited, e.err)
+	}
+	return ErrEngineExited
+}
+
+// kill ends the process. Its output is dropped, so the reader can get to
+// the end of it.
+func (e *Engine) kill() {
+	e.cmd.Process.Kill()
+	for range e.lines {
+	}
+	<-e.exited
+}
@@ -0,0 +1,267 @@
+package uci_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"ust_chess/internal/board"
+	"ust_chess/internal/types"
+	"ust_chess/internal/uci"
+)
+
+// TestFakeEngine is not a test: the other tests run the test binary with
+// UCI_FAKE_ENGINE set to get an engine that behaves as the mode says.
+func TestFakeEngine(t *testing.T) {
+	mode := os.Getenv("UCI_FAKE_ENGINE")
+	if mode == "" {
+		t.Skip("runs as an engine for the other tests")
+	}
+	log, err := os.OpenFile(os.Getenv("UCI_FAKE_LOG"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		os.Exit(2)
+	}
+	fakeEngine(mode, os.Stdin, os.Stdout, log)
+	os.Exit(0)
+}
+
+// fakeEngine answers with the first legal move of the position in
+// notation order. Modes:
+//   - "good" plays by the rules,
+//   - "mute" never gets ready,
+//   - "crash" exits when asked to search,
+//   - "stubborn" ignores "stop",
+//   - "cheat" plays an illegal move.
+func fakeEngine(mode string, in io.Reader, out io.Writer, log io.Writer) {
+	pos, _ := types.ParseFEN(types.StartFEN)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(log, line)
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "uci":
+			fmt.Fprintln(out, "id name Fake 1.0")
+			fmt.Fprintln(out, "option name Hash type spin default 16 min 1 max 1024")
+			fmt.Fprintln(out, "uciok")
+		case "isready":
+			if mode != "mute" {
+				fmt.Fprintln(out, "readyok")
+			}
+		case "position":
+			pos, _ = types.ParseFEN(types.StartFEN)
+			if fields[1] == "fen" {
+				pos, _ = types.ParseFEN(strings.Join(fields[2:8], " "))
+			}
+			if i := slices.Index(fields, "moves"); i >= 0 {
+				for _, notation := range fields[i+1:] {
+					move, _ := types.ParseMove(notation)
+					move, _ = pos.Legal(move)
+					pos.Make(move)
+				}
+			}
+		case "go":
+			if mode == "crash" {
+				os.Exit(3)
+			}
+			moves := pos.LegalMoves()
+			notations := make([]string, len(moves))
+			for i, move := range moves {
+				notations[i] = move.Notation()
+			}
+			slices.Sort(notations)
+			best := notations[0]
+			if mode == "cheat" {
+				best = "e2e5"
+			}
+			fmt.Fprintln(out, "info string thinking")
+			fmt.Fprintf(out, "info depth 1 seldepth 1 score cp 12 nodes 20 time 1 pv %s\n", best)
+			if slices.Contains(fields, "infinite") {
+				for scanner.Scan() && scanner.Text() != "stop" {
+				}
+				if mode == "stubborn" {
+					time.Sleep(time.Hour)
+				}
+			}
+			fmt.Fprintf(out, "info depth 2 score mate -3 nodes 420 time 5 pv %s\n", best)
+			fmt.Fprintf(out, "bestmove %s ponder a7a6\n", best)
+		case "quit":
+			return
+		}
+	}
+}
+
+func start(t *testing.T, mode string) (*uci.Engine, func() []string) {
+	t.Helper()
+	log := filepath.Join(t.TempDir(), "commands")
+	t.Setenv("UCI_FAKE_ENGINE", mode)
+	t.Setenv("UCI_FAKE_LOG", log)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	engine, err := uci.Start(ctx, os.Args[0], "-test.run=^TestFakeEngine$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Timeout = 200 * time.Millisecond
+	t.Cleanup(func() { engine.Close() })
+	commands := func() []string {
+		data, _ := os.ReadFile(log)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	return engine, commands
+}
+
+func game(t *testing.T, moves ...string) *board.Game {
+	t.Helper()
+	g := board.NewGame(nil)
+	for _, notation := range moves {
+		move, err := types.ParseMove(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.MakeMove(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &g
+}
+
+func TestPlay(t *testing.T) {
+	engine, commands := start(t, "good")
+	if engine.Name != "Fake 1.0" {
+		t.Errorf("name %q", engine.Name)
+	}
+
+	g := game(t, "e2e4", "e7e5")
+	if err := engine.Sync(g); err != nil {
+		t.Fatal(err)
+	}
+	var infos []uci.Info
+	result, err := engine.Go(context.Background(), uci.Limits{Depth: 2, WhiteTime: time.Minute, WhiteInc: time.Second}, func(info uci.Info) {
+		infos = append(infos, info)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Move.Notation() != "a2a3" || result.Ponder == nil || result.Ponder.Notation() != "a7a6" {
+		t.Errorf("got %+v", result)
+	}
+	if len(infos) != 2 || infos[0].Score != 12 || infos[0].Depth != 1 ||
+		infos[1].Mate != -3 || infos[1].Nodes != 420 || infos[1].Time != 5*time.Millisecond ||
+		len(infos[1].PV) != 1 || infos[1].PV[0] != result.Move {
+		t.Errorf("infos %+v", infos)
+	}
+
+	// The game goes on, then starts anew.
+	if err := g.MakeMove(result.Move); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*board.Game{g, g, game(t, "d2d4")} {
+		if err := engine.Sync(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := slices.DeleteFunc(commands(), func(command string) bool {
+		return command == "isready" || command == "uci"
+	})
+	want := []string{
+		"ucinewgame",
+		"position startpos moves e2e4 e7e5",
+		"go depth 2 wtime 60000 winc 1000",
+		"position startpos moves e2e4 e7e5 a2a3",
+		"ucinewgame",
+		"position startpos moves d2d4",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %q", sent)
+	}
+}
+
+func TestCustomStart(t *testing.T) {
+	engine, commands := start(t, "good")
+	pos, _ := types.ParseFEN("7k/P7/8/8/8/8/8/K7 w - -")
+	var pieces []types.Piece
+	for x := range 8 {
+		for y := range 8 {
+			if piece := pos.GetCell(types.MustNewPos(x, y)).GetPiece(); piece != nil {
+				pieces = append(pieces, *piece)
+			}
+		}
+	}
+	g := board.NewGame(pieces)
+	if err := engine.Sync(&g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Go(context.Background(), uci.Limits{MoveTime: time.Second}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(commands(), "position fen 7k/P7/8/8/8/8/8/K7 w - - 0 1") {
+		t.Errorf("sent %q", commands())
+	}
+}
+
+func TestStop(t *testing.T) {
+	engine, _ := start(t, "good")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := engine.Go(ctx, uci.Limits{}, nil)
+	if err != nil || result.Move.Notation() != "a2a3" {
+		t.Errorf("got %v, %v", result.Move.Notation(), err)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	t.Run("mute", func(t *testing.T) {
+		t.Setenv("UCI_FAKE_ENGINE", "mute")
+		t.Setenv("UCI_FAKE_LOG", filepath.Join(t.TempDir(), "commands"))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, err := uci.Start(ctx, os.Args[0], "-test.run=^TestFakeEngine$"); !errors.Is(err, uci.ErrTimeout) {
+			t.Errorf("started: %v", err)
+		}
+	})
+
+	t.Run("crash", func(t *testing.T) {
+		engine, _ := start(t, "crash")
+		if _, err := engine.Go(context.Background(), uci.Limits{Depth: 1}, nil); !errors.Is(err, uci.ErrEngineExited) {
+			t.Errorf("got %v", err)
+		}
+		if err := engine.Sync(game(t, "e2e4")); !errors.Is(err, uci.ErrEngineExited) {
+			t.Errorf("synced with a dead engine: %v", err)
+		}
+		var exit *exec.ExitError
+		if err := engine.Close(); !errors.Is(err, uci.ErrEngineExited) || !errors.As(err, &exit) || exit.ExitCode() != 3 {
+			t.Errorf("closed a dead engine: %v", err)
+		}
+	})
+
+	t.Run("stubborn", func(t *testing.T) {
+		engine, _ := start(t, "stubborn")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := engine.Go(ctx, uci.Limits{}, nil); !errors.Is(err, uci.ErrTimeout) {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("cheat", func(t *testing.T) {
+		engine, _ := start(t, "cheat")
+		if _, err := engine.Go(context.Background(), uci.Limits{Depth: 1}, nil); !errors.Is(err, uci.ErrBadOutput) {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := uci.Start(context.Background(), filepath.Join(t.TempDir(), "nothing")); err == nil {
+			t.Error("started nothing")
+		}
+	})
+}